@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"time"
 
 	"github.com/pkg/errors"
@@ -8,7 +9,7 @@ import (
 	"github.com/aws/aws-lambda-go/lambda"
 )
 
-func checkDeadline(request map[string]interface{}) (map[string]interface{}, error) {
+func checkDeadline(ctx context.Context, request map[string]interface{}) (map[string]interface{}, error) {
 	response := request
 	deadline, err := time.Parse(time.RFC3339, request["Deadline"].(string))
 	if err != nil {
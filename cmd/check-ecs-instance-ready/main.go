@@ -1,80 +1,76 @@
 package main
 
 import (
+	"context"
 	"fmt"
 
 	"github.com/aws/aws-lambda-go/lambda"
-	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/session"
-	"github.com/aws/aws-sdk-go/service/ecs"
 	"github.com/otterley/ec2-autoscaling-lifecycle-helpers/internal"
-	"github.com/pkg/errors"
+	"github.com/otterley/ec2-autoscaling-lifecycle-helpers/internal/checks"
 )
 
-func checkECSInstanceReady(request internal.ECSReadyParameters) (internal.ECSReadyParameters, error) {
+func checkECSInstanceReady(ctx context.Context, request internal.ECSReadyParameters) (internal.ECSReadyParameters, error) {
 	response := request
 	response.Ready = false
+	response.Partial = false
+	response.UnmetRequirements = nil
+
+	ctx, cancel := internal.WithSafetyMargin(ctx)
+	defer cancel()
 
 	sess := session.Must(session.NewSession())
-	client := ecs.New(sess)
 
-	if request.ECSInstanceID == "" {
-		var err error
-		request.ECSInstanceID, err = internal.GetECSInstanceARN(sess, request.ECSCluster, request.EC2InstanceID)
+	if request.CheckBaseline && !request.BaselineReady {
+		result, err := checks.CheckBaseline(ctx, sess, checks.BaselineConfig{}, request.EC2InstanceID)
 		if err != nil {
-			return response, errors.WithMessage(err, "GetECSInstanceARN")
+			if internal.OutOfTime(ctx) {
+				return outOfTime(response)
+			}
+			return response, err
 		}
-		if request.ECSInstanceID == "" {
-			// No instance ID assigned yet, so not ready
-			fmt.Printf("No ECS instance ID in cluster %s for EC2 instance ID %s\n", request.ECSCluster, request.EC2InstanceID)
+		result.Name = "ec2-baseline"
+		internal.PrintResult(result)
+		if !result.Ready {
 			return response, nil
 		}
+		response.BaselineReady = true
 	}
 
-	result, err := client.DescribeContainerInstances(
-		&ecs.DescribeContainerInstancesInput{
-			Cluster:            aws.String(request.ECSCluster),
-			ContainerInstances: aws.StringSlice([]string{request.ECSInstanceID}),
-		},
-	)
-	if err != nil {
-		return response, errors.WithMessage(err, "DescribeContainerInstances")
-	}
-	if len(result.ContainerInstances) != 1 {
-		return response, errors.New("assertion failure: container instances != 1")
+	config := checks.ECSInstanceConfig{
+		Clusters:             request.ECSClusters,
+		RequiredTaskFamilies: request.RequiredTaskFamilies,
+		Readiness:            request.Readiness,
+		Quarantine:           request.Quarantine,
 	}
-
-	if !aws.BoolValue(result.ContainerInstances[0].AgentConnected) ||
-		aws.StringValue(result.ContainerInstances[0].Status) != "ACTIVE" {
-		fmt.Printf("ECS instance %s not connected or state not ACTIVE\n", request.ECSInstanceID)
-		return response, nil
+	state := checks.ECSInstanceState{
+		Cluster:     request.ECSCluster,
+		InstanceID:  request.ECSInstanceID,
+		Quarantined: request.Quarantined,
 	}
-
-	for _, family := range request.RequiredTaskFamilies {
-		taskCount := 0
-		if err := client.ListTasksPages(
-			&ecs.ListTasksInput{
-				Cluster:           aws.String(request.ECSCluster),
-				ContainerInstance: aws.String(request.ECSInstanceID),
-				DesiredStatus:     aws.String("RUNNING"),
-				Family:            aws.String(family),
-			},
-			func(page *ecs.ListTasksOutput, lastPage bool) bool {
-				taskCount += len(page.TaskArns)
-				return !lastPage
-			},
-		); err != nil {
-			return response, errors.WithMessage(err, "ListTasks")
-		}
-		fmt.Printf("Task count for family %s on ECS instance %s: %d\n", family, request.ECSInstanceID, taskCount)
-		if taskCount == 0 {
-			fmt.Println("ECS instance not ready")
-			return response, nil
+	result, err := checks.CheckECSInstance(ctx, sess, config, request.EC2InstanceID, &state)
+	response.ECSCluster = state.Cluster
+	response.ECSInstanceID = state.InstanceID
+	response.Quarantined = state.Quarantined
+	if err != nil {
+		if internal.OutOfTime(ctx) {
+			return outOfTime(response)
 		}
+		return response, err
 	}
 
-	fmt.Println("ECS instance ready")
-	response.Ready = true
+	response.UnmetRequirements = result.Details
+	result.Name = "ecs-instance"
+	internal.PrintResult(result)
+	response.Ready = result.Ready
+	return response, nil
+}
+
+// outOfTime returns a not-ready result flagged as partial, so that the state
+// machine checks again on its next iteration.
+func outOfTime(response internal.ECSReadyParameters) (internal.ECSReadyParameters, error) {
+	fmt.Println("Ran out of time before readiness could be determined; will check again")
+	response.Partial = true
 	return response, nil
 }
 
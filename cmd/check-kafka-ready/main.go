@@ -1,61 +1,54 @@
 package main
 
 import (
+	"context"
 	"fmt"
-	"net"
 
-	"github.com/Shopify/sarama"
 	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/otterley/ec2-autoscaling-lifecycle-helpers/internal"
+	"github.com/otterley/ec2-autoscaling-lifecycle-helpers/internal/checks"
 )
 
-func checkKafkaReady(request internal.KafkaReadyParameters) (internal.KafkaReadyParameters, error) {
+func checkKafkaReady(ctx context.Context, request internal.KafkaReadyParameters) (internal.KafkaReadyParameters, error) {
 	response := request
 	response.Ready = false
+	response.Partial = false
 
-	// NOTE: All errors encountered here should be considered retriable.  Either
-	// print them and return nil, or ensure the Step Function that calls this
-	// function catches errors reported here.
+	ctx, cancel := internal.WithSafetyMargin(ctx)
+	defer cancel()
 
-	client, err := sarama.NewClient(
-		[]string{net.JoinHostPort(request.InternalIPAddr, string(request.KafkaPort))},
-		sarama.NewConfig(),
-	)
-	if err != nil {
-		fmt.Println(err)
-		return response, nil
-	}
-	topics, err := client.Topics()
-	if err != nil {
-		fmt.Println(err)
-		return response, nil
-	}
-	for _, topic := range topics {
-		partitions, err := client.Partitions(topic)
+	if request.CheckBaseline && !request.BaselineReady {
+		sess := session.Must(session.NewSession())
+		result, err := checks.CheckBaseline(ctx, sess, checks.BaselineConfig{}, request.EC2InstanceID)
 		if err != nil {
-			fmt.Println(err)
-			return response, nil
-		}
-		for _, partition := range partitions {
-			replicas, err := client.Replicas(topic, partition)
-			if err != nil {
-				fmt.Println(err)
-				return response, nil
-			}
-			isrs, err := client.InSyncReplicas(topic, partition)
-			if err != nil {
-				fmt.Println(err)
-				return response, nil
-			}
-			fmt.Printf("Topic %s[%d]: %d replicas, %d ISRs\n", topic, partition, len(replicas), len(isrs))
-			if len(replicas) != len(isrs) {
-				fmt.Println("Not in sync - exiting")
+			if internal.OutOfTime(ctx) {
+				fmt.Println("Ran out of time before the baseline was checked; will check again")
+				response.Partial = true
 				return response, nil
 			}
+			return response, err
+		}
+		result.Name = "ec2-baseline"
+		internal.PrintResult(result)
+		if !result.Ready {
+			return response, nil
 		}
+		response.BaselineReady = true
+	}
+
+	// Errors talking to Kafka are all retriable, so CheckKafka reports them
+	// as the reason the broker isn't ready rather than returning them.
+	result, err := checks.CheckKafka(ctx, request.InternalIPAddr, request.KafkaPort)
+	if err != nil {
+		fmt.Println("Ran out of time before all partitions were checked; will check again")
+		response.Partial = true
+		return response, nil
 	}
 
-	response.Ready = true
+	result.Name = "kafka"
+	internal.PrintResult(result)
+	response.Ready = result.Ready
 	return response, nil
 }
 
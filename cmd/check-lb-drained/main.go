@@ -0,0 +1,67 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/otterley/ec2-autoscaling-lifecycle-helpers/internal"
+	"github.com/pkg/errors"
+)
+
+// checkLBDrained reports whether the instance has finished deregistering from
+// every load balancer, i.e. whether connection draining is over.
+func checkLBDrained(ctx context.Context, request internal.LBDrainParameters) (internal.LBDrainParameters, error) {
+	response := request
+	response.Drained = false
+	response.Partial = false
+	response.Pending = nil
+
+	ctx, cancel := internal.WithSafetyMargin(ctx)
+	defer cancel()
+
+	sess := session.Must(session.NewSession())
+
+	for _, arn := range request.TargetGroupARNs {
+		pending, err := internal.InstanceTargetsDraining(ctx, sess, arn, request.EC2InstanceID)
+		if err != nil {
+			return outOfTime(ctx, response, errors.WithMessage(err, arn))
+		}
+		response.Pending = append(response.Pending, pending...)
+	}
+	for _, name := range request.LoadBalancerNames {
+		pending, err := internal.InstanceLoadBalancerDraining(ctx, sess, name, request.EC2InstanceID)
+		if err != nil {
+			return outOfTime(ctx, response, errors.WithMessage(err, name))
+		}
+		if pending != "" {
+			response.Pending = append(response.Pending, pending)
+		}
+	}
+
+	for _, pending := range response.Pending {
+		fmt.Printf("Still draining: %s\n", pending)
+	}
+	response.Drained = len(response.Pending) == 0
+	if response.Drained {
+		fmt.Printf("EC2 instance %s has been drained from all load balancers\n", request.EC2InstanceID)
+	}
+	return response, nil
+}
+
+// outOfTime marks the response Partial instead of returning err if the
+// handler ran out of time, so that draining is checked again.
+func outOfTime(ctx context.Context, response internal.LBDrainParameters, err error) (internal.LBDrainParameters, error) {
+	if !internal.OutOfTime(ctx) {
+		return response, err
+	}
+	fmt.Println("Ran out of time before draining could be checked; will check again")
+	response.Partial = true
+	response.Pending = nil
+	return response, nil
+}
+
+func main() {
+	lambda.Start(checkLBDrained)
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/otterley/ec2-autoscaling-lifecycle-helpers/internal"
+)
+
+// agentRequest holds the parameters of either drain workflow that the check
+// needs.  Its result is stored in their Agent field.
+type agentRequest struct {
+	internal.AutoScalingLifecycleEvent
+	internal.AgentParameters
+}
+
+// checkLifecycleAgent reports the lifecycle agent on the instance Finished
+// once it has reported the outcome of its Terminated hooks, or at once if
+// WaitForAgent isn't set.
+func checkLifecycleAgent(ctx context.Context, request agentRequest) (internal.AgentResult, error) {
+	if !request.WaitForAgent {
+		return internal.AgentResult{Finished: true}, nil
+	}
+
+	sess := session.Must(session.NewSession())
+	status, err := internal.AgentStatus(ctx, sess, request.EC2InstanceID, "Terminated")
+	if err != nil {
+		return internal.AgentResult{}, err
+	}
+	if status == "" {
+		fmt.Printf("Lifecycle agent on EC2 instance %s hasn't finished its Terminated hooks\n", request.EC2InstanceID)
+		return internal.AgentResult{}, nil
+	}
+	fmt.Printf("Lifecycle agent on EC2 instance %s finished its Terminated hooks: %s\n", request.EC2InstanceID, status)
+	return internal.AgentResult{Finished: true, Status: status}, nil
+}
+
+func main() {
+	lambda.Start(checkLifecycleAgent)
+}
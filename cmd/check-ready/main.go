@@ -0,0 +1,49 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/otterley/ec2-autoscaling-lifecycle-helpers/internal"
+	_ "github.com/otterley/ec2-autoscaling-lifecycle-helpers/internal/checks"
+	"github.com/pkg/errors"
+)
+
+// checkReady evaluates the configured readiness check, which may combine any
+// number of registered checks.
+func checkReady(ctx context.Context, request internal.CheckParameters) (internal.CheckParameters, error) {
+	response := request
+	response.Ready = false
+	response.Partial = false
+	response.Result = nil
+
+	ctx, cancel := internal.WithSafetyMargin(ctx)
+	defer cancel()
+
+	check, err := internal.NewCheck(request.Check)
+	if err != nil {
+		return response, errors.WithMessage(err, "NewCheck")
+	}
+
+	result, err := check.Evaluate(ctx, &response)
+	if err != nil {
+		if internal.OutOfTime(ctx) {
+			fmt.Println("Ran out of time before readiness could be determined; will check again")
+			response.Partial = true
+			return response, nil
+		}
+		// Like a member of a composite check, a check that fails is not
+		// ready, and is evaluated again on the next iteration.
+		result = internal.Result{Name: check.Name(), Reason: err.Error()}
+	}
+
+	internal.PrintResult(result)
+	response.Result = &result
+	response.Ready = result.Ready
+	return response, nil
+}
+
+func main() {
+	lambda.Start(checkReady)
+}
@@ -1,19 +1,22 @@
 package main
 
 import (
+	"context"
+
 	"github.com/aws/aws-lambda-go/lambda"
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/autoscaling"
 )
 
-func putLifecycleAction(request map[string]interface{}) (map[string]interface{}, error) {
+func putLifecycleAction(ctx context.Context, request map[string]interface{}) (map[string]interface{}, error) {
 	response := request
 	client := autoscaling.New(session.Must(session.NewSession()))
 
 	params := request["Params"].(map[string]interface{})
 
-	_, err := client.CompleteLifecycleAction(
+	_, err := client.CompleteLifecycleActionWithContext(
+		ctx,
 		&autoscaling.CompleteLifecycleActionInput{
 			AutoScalingGroupName:  aws.String(request["AutoScalingGroupName"].(string)),
 			InstanceId:            aws.String(request["EC2InstanceId"].(string)),
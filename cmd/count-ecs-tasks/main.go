@@ -1,29 +1,156 @@
 package main
 
 import (
+	"context"
+	"fmt"
+	"time"
+
 	"github.com/aws/aws-lambda-go/lambda"
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/client"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/ecs"
 	"github.com/otterley/ec2-autoscaling-lifecycle-helpers/internal"
+	"github.com/pkg/errors"
 )
 
-func countECSTasks(request internal.DrainParameters) (internal.DrainParameters, error) {
+func countECSTasks(ctx context.Context, request internal.DrainParameters) (internal.DrainParameters, error) {
 	response := request
 	response.ECSTaskCount = 0
+	response.ProtectedTaskCount = 0
+	response.IgnoredTasks = nil
+	response.ServicesSettled = false
+	response.UnsettledServices = nil
+	response.Partial = false
 
-	client := ecs.New(session.Must(session.NewSession()))
-	return response, client.ListTasksPages(
-		&ecs.ListTasksInput{
-			Cluster:           aws.String(request.ECSCluster),
-			ContainerInstance: aws.String(request.ECSInstanceID),
-			DesiredStatus:     aws.String("RUNNING"),
-		},
-		func(page *ecs.ListTasksOutput, lastPage bool) bool {
-			response.ECSTaskCount += len(page.TaskArns)
-			return !lastPage
+	opCtx, cancel := internal.WithSafetyMargin(ctx)
+	defer cancel()
+
+	sess := session.Must(session.NewSession())
+
+	var err error
+	if request.CountSelector == nil && !request.IgnoreDaemonServiceTasks && len(request.IgnoredTaskFamilies) == 0 {
+		err = ecs.New(sess).ListTasksPagesWithContext(
+			opCtx,
+			&ecs.ListTasksInput{
+				Cluster:           aws.String(request.ECSCluster),
+				ContainerInstance: aws.String(request.ECSInstanceID),
+				DesiredStatus:     aws.String("RUNNING"),
+			},
+			func(page *ecs.ListTasksOutput, lastPage bool) bool {
+				response.ECSTaskCount += len(page.TaskArns)
+				return !lastPage
+			},
+		)
+		err = errors.WithMessage(err, "ListTasks")
+	} else {
+		err = countFilteredTasks(opCtx, sess, &response)
+	}
+	if err != nil {
+		if internal.OutOfTime(opCtx) {
+			fmt.Printf("Ran out of time after counting %d tasks; will count again\n", response.ECSTaskCount)
+			response.Partial = true
+			return response, nil
+		}
+		return response, err
+	}
+
+	for _, ignored := range response.IgnoredTasks {
+		fmt.Printf("Not counting task %s: %s\n", ignored.TaskARN, ignored.Reason)
+	}
+	fmt.Printf("Task count on ECS instance %s: %d (%d ignored, %d scale-in protected)\n",
+		request.ECSInstanceID, response.ECSTaskCount, len(response.IgnoredTasks), response.ProtectedTaskCount)
+
+	// Replacements can only be waited upon once the instance is empty
+	if !request.WaitForServiceReplacements || len(request.DrainedServices) == 0 {
+		response.ServicesSettled = true
+	} else if response.ECSTaskCount == 0 {
+		response.UnsettledServices, err = internal.UnsettledServices(opCtx, sess, request.ECSCluster, request.DrainedServices)
+		if err != nil {
+			if internal.OutOfTime(opCtx) {
+				fmt.Println("Ran out of time while checking services; will check again")
+				response.Partial = true
+				return response, nil
+			}
+			return response, errors.WithMessage(err, "UnsettledServices")
+		}
+		for _, reason := range response.UnsettledServices {
+			fmt.Printf("Waiting for service %s\n", reason)
+		}
+		response.ServicesSettled = len(response.UnsettledServices) == 0
+	}
+	return response, nil
+}
+
+// countFilteredTasks counts the tasks that hold up the drain: those chosen by
+// the count selector, if any, that don't belong to a DAEMON service or an
+// ignored task family.  Tasks with scale-in protection hold up the drain
+// until their protection ends, unless they belong to a DAEMON service.
+func countFilteredTasks(ctx context.Context, sess client.ConfigProvider, response *internal.DrainParameters) error {
+	var tasks []*ecs.Task
+	if err := internal.DescribeInstanceTasks(ctx, sess, response.ECSCluster, response.ECSInstanceID,
+		func(page []*ecs.Task, failures []*ecs.Failure) bool {
+			tasks = append(tasks, page...)
+			return true
 		},
-	)
+	); err != nil {
+		return err
+	}
+
+	var arns []string
+	for _, task := range tasks {
+		arns = append(arns, aws.StringValue(task.TaskArn))
+	}
+	protected, err := internal.ProtectedTasks(ctx, sess, response.ECSCluster, arns)
+	if err != nil {
+		return err
+	}
+
+	daemons := make(map[string]bool)
+	if response.IgnoreDaemonServiceTasks {
+		var services []string
+		seen := make(map[string]bool)
+		for _, task := range tasks {
+			if name := internal.TaskServiceName(task); name != "" && !seen[name] {
+				seen[name] = true
+				services = append(services, name)
+			}
+		}
+		if daemons, err = internal.DaemonServices(ctx, sess, response.ECSCluster, services); err != nil {
+			return err
+		}
+	}
+
+	families := make(map[string]bool)
+	for _, family := range response.IgnoredTaskFamilies {
+		families[family] = true
+	}
+
+	for _, task := range tasks {
+		if name := internal.TaskServiceName(task); daemons[name] {
+			response.IgnoredTasks = append(response.IgnoredTasks, internal.TaskOutcome{
+				TaskARN: aws.StringValue(task.TaskArn),
+				Reason:  fmt.Sprintf("belongs to DAEMON service %s", name),
+			})
+			continue
+		}
+		if response.CountSelector != nil && !response.CountSelector.Matches(task) {
+			continue
+		}
+		if family := internal.TaskFamily(task); families[family] {
+			response.IgnoredTasks = append(response.IgnoredTasks, internal.TaskOutcome{
+				TaskARN: aws.StringValue(task.TaskArn),
+				Reason:  fmt.Sprintf("task family %s is ignored", family),
+			})
+			continue
+		}
+		if expires, ok := protected[aws.StringValue(task.TaskArn)]; ok {
+			fmt.Printf("Task %s is scale-in protected until %s\n", aws.StringValue(task.TaskArn), expires.Format(time.RFC3339))
+			response.ProtectedTaskCount++
+		}
+		response.ECSTaskCount++
+	}
+	return nil
 }
 
 func main() {
@@ -1,6 +1,8 @@
 package main
 
 import (
+	"context"
+
 	"github.com/aws/aws-lambda-go/lambda"
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/session"
@@ -8,14 +10,15 @@ import (
 	"github.com/pkg/errors"
 )
 
-func countRunningExecutions(request map[string]interface{}) (response map[string]interface{}, err error) {
+func countRunningExecutions(ctx context.Context, request map[string]interface{}) (response map[string]interface{}, err error) {
 	response = request
 	count := 0
 
 	sess := session.Must(session.NewSession())
 
 	client := sfn.New(sess)
-	if err := client.ListExecutionsPages(
+	if err := client.ListExecutionsPagesWithContext(
+		ctx,
 		&sfn.ListExecutionsInput{
 			StateMachineArn: aws.String(request["StateMachineARN"].(string)),
 			StatusFilter:    aws.String("RUNNING"),
@@ -0,0 +1,18 @@
+package main
+
+import (
+	"context"
+
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/otterley/ec2-autoscaling-lifecycle-helpers/internal"
+)
+
+func deleteTaskToken(ctx context.Context, request internal.CallbackParameters) (internal.CallbackParameters, error) {
+	sess := session.Must(session.NewSession())
+	return request, internal.DeleteTaskToken(ctx, sess, request.TokenParameterName)
+}
+
+func main() {
+	lambda.Start(deleteTaskToken)
+}
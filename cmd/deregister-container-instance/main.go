@@ -0,0 +1,46 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/ecs"
+	"github.com/otterley/ec2-autoscaling-lifecycle-helpers/internal"
+)
+
+// deregisterContainerInstance removes the drained container instance from the
+// cluster, so that it stops counting towards the cluster's capacity before
+// ECS notices the EC2 instance is gone.  Errors are reported in the response
+// rather than returned, since the instance is terminated either way.
+func deregisterContainerInstance(ctx context.Context, request internal.DrainParameters) (internal.DrainParameters, error) {
+	response := request
+	if !request.DeregisterInstance || request.Deregistered {
+		return response, nil
+	}
+
+	fmt.Printf("Deregistering ECS instance %s from cluster %s (force: %t)\n", request.ECSInstanceID, request.ECSCluster, request.ForceDeregister)
+	_, err := ecs.New(session.Must(session.NewSession())).DeregisterContainerInstanceWithContext(
+		ctx,
+		&ecs.DeregisterContainerInstanceInput{
+			Cluster:           aws.String(request.ECSCluster),
+			ContainerInstance: aws.String(request.ECSInstanceID),
+			Force:             aws.Bool(request.ForceDeregister),
+		},
+	)
+	if err != nil {
+		fmt.Printf("Failed to deregister ECS instance %s: %v\n", request.ECSInstanceID, err)
+		response.DeregisterError = err.Error()
+		return response, nil
+	}
+	fmt.Printf("Deregistered ECS instance %s\n", request.ECSInstanceID)
+	response.Deregistered = true
+	response.DeregisterError = ""
+	return response, nil
+}
+
+func main() {
+	lambda.Start(deregisterContainerInstance)
+}
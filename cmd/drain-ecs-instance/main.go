@@ -0,0 +1,52 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/otterley/ec2-autoscaling-lifecycle-helpers/internal"
+	"github.com/pkg/errors"
+)
+
+// drainECSInstance is invoked on each poll iteration until the ECS instance
+// is DRAINING.  It drains the instance as soon as the rest of the cluster has
+// room for its tasks.  Afterwards it's invoked while selected tasks are kept
+// from stopping by scale-in protection, to try stopping them again.
+func drainECSInstance(ctx context.Context, request internal.DrainParameters) (internal.DrainParameters, error) {
+	response := request
+	if request.Draining && !request.ProtectedTasksPending {
+		return response, nil
+	}
+
+	opCtx, cancel := internal.WithSafetyMargin(ctx)
+	defer cancel()
+
+	sess := session.Must(session.NewSession())
+	if request.Draining {
+		if err := internal.RetryProtectedTasks(opCtx, sess, &response); err != nil {
+			if internal.OutOfTime(opCtx) {
+				fmt.Println("Ran out of time while stopping protected tasks; will try again")
+				return response, nil
+			}
+			return response, errors.WithMessage(err, "RetryProtectedTasks")
+		}
+		return response, nil
+	}
+
+	if err := internal.DrainIfCapacityAvailable(opCtx, sess, &response); err != nil {
+		if internal.OutOfTime(opCtx) {
+			// Draining is idempotent, so the next iteration can try again
+			fmt.Println("Ran out of time while draining; will try again")
+			response.Draining = false
+			return response, nil
+		}
+		return response, errors.WithMessage(err, "DrainIfCapacityAvailable")
+	}
+	return response, nil
+}
+
+func main() {
+	lambda.Start(drainECSInstance)
+}
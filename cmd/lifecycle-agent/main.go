@@ -0,0 +1,161 @@
+// lifecycle-agent runs on an instance and watches its Auto Scaling target
+// lifecycle state in the instance metadata.  Each time the state changes, it
+// runs the executable files in the hooks directory named after the new state
+// (e.g. /etc/lifecycle-agent/hooks/Terminated) in lexical order, then tags the
+// instance with the outcome so that the drain workflows can wait on it.  It
+// exits once the Terminated hooks have run and been reported.
+//
+// The instance role must allow ec2:CreateTags on the instance for tags with
+// the "lifecycle-agent:" prefix.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/ec2metadata"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/otterley/ec2-autoscaling-lifecycle-helpers/internal"
+	"github.com/pkg/errors"
+)
+
+type agent struct {
+	metadata *ec2metadata.EC2Metadata
+	hooksDir string
+	timeout  time.Duration
+	interval time.Duration
+	report   func(ctx context.Context, state, status string) error
+
+	state    string
+	status   string
+	reported bool
+}
+
+// poll reads the target lifecycle state, runs its hooks if it has changed,
+// and reports their outcome.  A failed report is retried on the next poll
+// without running the hooks again.
+func (a *agent) poll(ctx context.Context) error {
+	state, err := a.metadata.GetMetadataWithContext(ctx, "autoscaling/target-lifecycle-state")
+	if err != nil {
+		return errors.WithMessage(err, "target-lifecycle-state")
+	}
+	if state != a.state {
+		fmt.Printf("Target lifecycle state changed from %q to %q\n", a.state, state)
+		a.state = state
+		a.status = internal.AgentDone
+		if err := runHooks(ctx, filepath.Join(a.hooksDir, state), state, a.timeout); err != nil {
+			fmt.Printf("Hooks for %s failed: %v\n", state, err)
+			a.status = internal.AgentFailed
+		}
+		a.reported = false
+	}
+	if !a.reported {
+		if err := a.report(ctx, a.state, a.status); err != nil {
+			return errors.WithMessage(err, "report")
+		}
+		a.reported = true
+	}
+	return nil
+}
+
+// run polls until the Terminated hooks have been run and reported, or ctx is
+// done.
+func (a *agent) run(ctx context.Context) error {
+	for {
+		if err := a.poll(ctx); err != nil {
+			fmt.Println(err)
+		}
+		if a.state == "Terminated" && a.reported {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(a.interval):
+		}
+	}
+}
+
+// runHooks runs the executable files in dir in lexical order, stopping at the
+// first to fail.  Each is killed, along with any processes it started, if it
+// runs for longer than timeout.  A missing dir has no hooks.
+func runHooks(ctx context.Context, dir, state string, timeout time.Duration) error {
+	files, err := ioutil.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	for _, file := range files {
+		if file.IsDir() || file.Mode()&0111 == 0 {
+			continue
+		}
+		path := filepath.Join(dir, file.Name())
+		fmt.Printf("Running %s\n", path)
+		if err := runHook(ctx, path, state, timeout); err != nil {
+			return errors.WithMessage(err, path)
+		}
+	}
+	return nil
+}
+
+func runHook(ctx context.Context, path, state string, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, path)
+	cmd.Env = append(os.Environ(), "LIFECYCLE_STATE="+state)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	// The hook runs in its own process group so that anything it started
+	// is killed along with it, and doesn't hold its output open.
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	cmd.Cancel = func() error {
+		return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+	}
+	cmd.WaitDelay = time.Second
+	err := cmd.Run()
+	if ctx.Err() == context.DeadlineExceeded {
+		return errors.Errorf("timed out after %s", timeout)
+	}
+	return err
+}
+
+func main() {
+	hooksDir := flag.String("hooks", "/etc/lifecycle-agent/hooks", "directory containing a directory of hooks for each target lifecycle state")
+	timeout := flag.Duration("hook-timeout", 10*time.Minute, "how long each hook may run before it's killed and counted as failed")
+	interval := flag.Duration("interval", 5*time.Second, "how often to poll the target lifecycle state")
+	flag.Parse()
+
+	ctx := context.Background()
+	sess := session.Must(session.NewSession())
+	metadata := ec2metadata.New(sess)
+	identity, err := metadata.GetInstanceIdentityDocumentWithContext(ctx)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, errors.WithMessage(err, "GetInstanceIdentityDocument"))
+		os.Exit(1)
+	}
+	regional := session.Must(session.NewSession(aws.NewConfig().WithRegion(identity.Region)))
+
+	a := &agent{
+		metadata: metadata,
+		hooksDir: *hooksDir,
+		timeout:  *timeout,
+		interval: *interval,
+		report: func(ctx context.Context, state, status string) error {
+			return internal.SetAgentStatus(ctx, regional, identity.InstanceID, state, status)
+		},
+	}
+	if err := a.run(ctx); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/ec2metadata"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/otterley/ec2-autoscaling-lifecycle-helpers/internal"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeIMDS stands in for the instance metadata service, requiring IMDSv2
+// session tokens as a real instance would.
+type fakeIMDS struct {
+	mu    sync.Mutex
+	state string
+}
+
+func (f *fakeIMDS) setState(state string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.state = state
+}
+
+func (f *fakeIMDS) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch {
+	case r.Method == http.MethodPut && r.URL.Path == "/latest/api/token":
+		w.Header().Set("x-aws-ec2-metadata-token-ttl-seconds", "21600")
+		w.Write([]byte("token"))
+	case r.Header.Get("x-aws-ec2-metadata-token") != "token":
+		w.WriteHeader(http.StatusUnauthorized)
+	case r.URL.Path == "/latest/meta-data/autoscaling/target-lifecycle-state":
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		w.Write([]byte(f.state))
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+type report struct {
+	State  string
+	Status string
+}
+
+// newTestAgent returns an agent polling imds, which moves the instance to
+// Terminated once its InService hooks are reported.
+func newTestAgent(t *testing.T, imds *fakeIMDS, hooksDir string, reports *[]report) *agent {
+	server := httptest.NewServer(imds)
+	t.Cleanup(server.Close)
+
+	sess := session.Must(session.NewSession())
+	return &agent{
+		metadata: ec2metadata.New(sess, aws.NewConfig().WithEndpoint(server.URL).WithMaxRetries(0)),
+		hooksDir: hooksDir,
+		timeout:  5 * time.Second,
+		interval: 10 * time.Millisecond,
+		report: func(ctx context.Context, state, status string) error {
+			*reports = append(*reports, report{state, status})
+			if state == "InService" {
+				imds.setState("Terminated")
+			}
+			return nil
+		},
+	}
+}
+
+func writeHook(t *testing.T, hooksDir, state, name, script string) {
+	dir := filepath.Join(hooksDir, state)
+	assert.NoError(t, os.MkdirAll(dir, 0755))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, name), []byte("#!/bin/sh\n"+script+"\n"), 0755))
+}
+
+func TestAgentRunsHooksForEachState(t *testing.T) {
+	hooksDir := t.TempDir()
+	out := filepath.Join(t.TempDir(), "out")
+	writeHook(t, hooksDir, "InService", "10-first", "echo first $LIFECYCLE_STATE >> "+out)
+	writeHook(t, hooksDir, "InService", "20-second", "echo second $LIFECYCLE_STATE >> "+out)
+	writeHook(t, hooksDir, "Terminated", "10-flush", "echo flush $LIFECYCLE_STATE >> "+out)
+
+	var reports []report
+	a := newTestAgent(t, &fakeIMDS{state: "InService"}, hooksDir, &reports)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	assert.NoError(t, a.run(ctx))
+
+	assert.Equal(t, []report{
+		{"InService", internal.AgentDone},
+		{"Terminated", internal.AgentDone},
+	}, reports)
+	output, err := ioutil.ReadFile(out)
+	assert.NoError(t, err)
+	assert.Equal(t, "first InService\nsecond InService\nflush Terminated\n", string(output))
+}
+
+func TestAgentReportsFailedHooks(t *testing.T) {
+	hooksDir := t.TempDir()
+	out := filepath.Join(t.TempDir(), "out")
+	writeHook(t, hooksDir, "Terminated", "10-fail", "exit 1")
+	writeHook(t, hooksDir, "Terminated", "20-skipped", "echo skipped >> "+out)
+
+	var reports []report
+	a := newTestAgent(t, &fakeIMDS{state: "InService"}, hooksDir, &reports)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	assert.NoError(t, a.run(ctx))
+
+	assert.Equal(t, []report{
+		{"InService", internal.AgentDone},
+		{"Terminated", internal.AgentFailed},
+	}, reports)
+	_, err := os.Stat(out)
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestAgentTimesOutHooks(t *testing.T) {
+	hooksDir := t.TempDir()
+	writeHook(t, hooksDir, "Terminated", "10-hang", "sleep 60")
+
+	var reports []report
+	a := newTestAgent(t, &fakeIMDS{state: "Terminated"}, hooksDir, &reports)
+	a.timeout = 100 * time.Millisecond
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	assert.NoError(t, a.run(ctx))
+	assert.Equal(t, []report{{"Terminated", internal.AgentFailed}}, reports)
+}
+
+func TestAgentRetriesFailedReport(t *testing.T) {
+	var reports []report
+	imds := &fakeIMDS{state: "Terminated"}
+	a := newTestAgent(t, imds, t.TempDir(), &reports)
+	report := a.report
+	failures := 2
+	a.report = func(ctx context.Context, state, status string) error {
+		if failures > 0 {
+			failures--
+			return assert.AnError
+		}
+		return report(ctx, state, status)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	assert.NoError(t, a.run(ctx))
+	assert.Len(t, reports, 1)
+}
@@ -0,0 +1,25 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/otterley/ec2-autoscaling-lifecycle-helpers/internal"
+)
+
+// publishTaskToken hands the task token the execution is waiting on to the
+// instance, which uses it to signal its readiness.
+func publishTaskToken(ctx context.Context, request internal.TaskTokenRequest) error {
+	sess := session.Must(session.NewSession())
+	if err := internal.PutTaskToken(ctx, sess, request.Input.TokenParameterName, request.TaskToken); err != nil {
+		return err
+	}
+	fmt.Printf("Published task token for EC2 instance %s as %s\n", request.Input.EC2InstanceID, request.Input.TokenParameterName)
+	return nil
+}
+
+func main() {
+	lambda.Start(publishTaskToken)
+}
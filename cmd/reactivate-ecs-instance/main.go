@@ -0,0 +1,116 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/aws/aws-sdk-go/aws/client"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/otterley/ec2-autoscaling-lifecycle-helpers/internal"
+	"github.com/pkg/errors"
+)
+
+// standbyMarker is the drain marker of an instance drained because it
+// entered Standby.
+const standbyMarker = "EnterStandby"
+
+// reactivationEvent is either an Auto Scaling instance launch event or a
+// CloudTrail record of an EnterStandby or ExitStandby call.
+type reactivationEvent struct {
+	DetailType string `json:"detail-type"`
+	Detail     struct {
+		EC2InstanceID     string `json:"EC2InstanceId"`
+		EventName         string `json:"eventName"`
+		RequestParameters struct {
+			InstanceIDs []string `json:"instanceIds"`
+		} `json:"requestParameters"`
+	} `json:"detail"`
+}
+
+// reactivateECSInstance drains container instances that enter Standby, and
+// sets those drained by the drainer back to ACTIVE when they leave Standby or
+// return to service, e.g. from a warm pool.  Instances drained by anyone else
+// are left alone.
+func reactivateECSInstance(ctx context.Context, event reactivationEvent) error {
+	clusters := internal.EnvList("ECS_CLUSTER")
+	if len(clusters) == 0 {
+		return errors.New("ECS_CLUSTER environment variable not defined")
+	}
+
+	sess := session.Must(session.NewSession())
+	switch {
+	case event.DetailType == "EC2 Instance Launch Successful":
+		return reactivate(ctx, sess, clusters, event.Detail.EC2InstanceID)
+	case event.Detail.EventName == "ExitStandby":
+		for _, id := range event.Detail.RequestParameters.InstanceIDs {
+			if err := reactivate(ctx, sess, clusters, id); err != nil {
+				return err
+			}
+		}
+	case event.Detail.EventName == "EnterStandby":
+		for _, id := range event.Detail.RequestParameters.InstanceIDs {
+			if err := drain(ctx, sess, clusters, id); err != nil {
+				return err
+			}
+		}
+	default:
+		fmt.Printf("Ignoring event %s %s\n", event.DetailType, event.Detail.EventName)
+	}
+	return nil
+}
+
+func reactivate(ctx context.Context, sess client.ConfigProvider, clusters []string, ec2InstanceID string) error {
+	cluster, id, err := internal.FindECSInstance(ctx, sess, clusters, ec2InstanceID)
+	if err != nil {
+		return errors.WithMessage(err, "FindECSInstance")
+	}
+	if id == "" {
+		fmt.Printf("No ECS instance matching EC2 instance ID %s\n", ec2InstanceID)
+		return nil
+	}
+	status, marker, err := internal.DrainMarker(ctx, sess, cluster, id)
+	if err != nil {
+		return errors.WithMessage(err, "DrainMarker")
+	}
+	if marker == "" {
+		fmt.Printf("ECS instance %s (%s) wasn't drained by us; leaving it alone\n", id, status)
+		return nil
+	}
+	if status == "DRAINING" {
+		fmt.Printf("Setting ECS instance %s on cluster %s to ACTIVE state\n", id, cluster)
+		if err := internal.SetContainerInstanceStatus(ctx, sess, cluster, id, "ACTIVE"); err != nil {
+			return err
+		}
+	}
+	return internal.ClearDrainMarker(ctx, sess, cluster, id)
+}
+
+func drain(ctx context.Context, sess client.ConfigProvider, clusters []string, ec2InstanceID string) error {
+	cluster, id, err := internal.FindECSInstance(ctx, sess, clusters, ec2InstanceID)
+	if err != nil {
+		return errors.WithMessage(err, "FindECSInstance")
+	}
+	if id == "" {
+		fmt.Printf("No ECS instance matching EC2 instance ID %s\n", ec2InstanceID)
+		return nil
+	}
+	status, marker, err := internal.DrainMarker(ctx, sess, cluster, id)
+	if err != nil {
+		return errors.WithMessage(err, "DrainMarker")
+	}
+	if status == "DRAINING" && marker == "" {
+		fmt.Printf("ECS instance %s is already DRAINING; leaving it alone\n", id)
+		return nil
+	}
+	// Mark it first, so that it's reactivated even if we fail part way
+	if err := internal.SetDrainMarker(ctx, sess, cluster, id, standbyMarker); err != nil {
+		return err
+	}
+	fmt.Printf("Setting ECS instance %s on cluster %s to DRAINING state\n", id, cluster)
+	return internal.SetContainerInstanceStatus(ctx, sess, cluster, id, "DRAINING")
+}
+
+func main() {
+	lambda.Start(reactivateECSInstance)
+}
@@ -1,17 +1,20 @@
 package main
 
 import (
+	"context"
+
 	"github.com/aws/aws-lambda-go/lambda"
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/autoscaling"
 )
 
-func recordLifecycleHeartbeat(request map[string]interface{}) (map[string]interface{}, error) {
+func recordLifecycleHeartbeat(ctx context.Context, request map[string]interface{}) (map[string]interface{}, error) {
 	response := request
 	client := autoscaling.New(session.Must(session.NewSession()))
 
-	_, err := client.RecordLifecycleActionHeartbeat(
+	_, err := client.RecordLifecycleActionHeartbeatWithContext(
+		ctx,
 		&autoscaling.RecordLifecycleActionHeartbeatInput{
 			AutoScalingGroupName: aws.String(request["AutoScalingGroupName"].(string)),
 			InstanceId:           aws.String(request["EC2InstanceId"].(string)),
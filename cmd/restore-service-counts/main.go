@@ -0,0 +1,29 @@
+package main
+
+import (
+	"context"
+
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/otterley/ec2-autoscaling-lifecycle-helpers/internal"
+	"github.com/pkg/errors"
+)
+
+// restoreServiceCounts undoes any over-provisioning of the drained instance's
+// services.  Services already restored are skipped, so it's safe to retry.
+func restoreServiceCounts(ctx context.Context, request internal.DrainParameters) (internal.DrainParameters, error) {
+	response := request
+	if len(request.ServiceOverProvisions) == 0 {
+		return response, nil
+	}
+
+	sess := session.Must(session.NewSession())
+	if err := internal.RestoreDesiredCounts(ctx, sess, &response); err != nil {
+		return response, errors.WithMessage(err, "RestoreDesiredCounts")
+	}
+	return response, nil
+}
+
+func main() {
+	lambda.Start(restoreServiceCounts)
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/otterley/ec2-autoscaling-lifecycle-helpers/internal"
+	"github.com/pkg/errors"
+)
+
+// drainCommandShare is the share of the time left before the deadline that
+// the drain command is given, so that the drain itself isn't left without
+// time.
+const drainCommandShare = 0.5
+
+// runDrainCommand is invoked on each poll iteration until the drain command
+// has finished.  The first invocation sends the command to the instance;
+// later ones record its progress, giving up on it once it has taken its
+// share of the time left before the deadline.
+func runDrainCommand(ctx context.Context, request internal.DrainParameters) (internal.DrainParameters, error) {
+	response := request
+	if request.DrainCommand == nil || request.DrainCommandDone {
+		response.DrainCommandDone = true
+		return response, nil
+	}
+
+	sess := session.Must(session.NewSession())
+	if request.DrainCommandRun == nil {
+		id, err := internal.SendCommand(ctx, sess, *request.DrainCommand, request.EC2InstanceID)
+		if err != nil {
+			return response, errors.WithMessage(err, "SendCommand")
+		}
+		fmt.Printf("Sent drain command %s to EC2 instance %s\n", id, request.EC2InstanceID)
+		response.DrainCommandRun = &internal.CommandRun{CommandID: id}
+		if request.Deadline != "" {
+			deadline, err := time.Parse(time.RFC3339, request.Deadline)
+			if err != nil {
+				return response, errors.WithMessage(err, "Deadline")
+			}
+			now := time.Now()
+			giveUp := now.Add(time.Duration(float64(deadline.Sub(now)) * drainCommandShare))
+			response.DrainCommandGiveUp = giveUp.UTC().Format(time.RFC3339)
+		}
+		return response, nil
+	}
+
+	run := *request.DrainCommandRun
+	if err := internal.PollCommand(ctx, sess, *request.DrainCommand, request.EC2InstanceID, &run); err != nil {
+		return response, errors.WithMessage(err, "PollCommand")
+	}
+	response.DrainCommandRun = &run
+	fmt.Printf("Drain %s\n", run)
+	if !run.Done {
+		if request.DrainCommandGiveUp == "" {
+			return response, nil
+		}
+		giveUp, err := time.Parse(time.RFC3339, request.DrainCommandGiveUp)
+		if err != nil {
+			return response, errors.WithMessage(err, "DrainCommandGiveUp")
+		}
+		if time.Now().Before(giveUp) {
+			return response, nil
+		}
+		fmt.Printf("Drain command hasn't finished by %s; draining anyway\n", request.DrainCommandGiveUp)
+		response.DrainCommandDone = true
+		return response, nil
+	}
+	if !run.Succeeded {
+		fmt.Printf("Drain command failed; draining anyway\nstdout: %s\nstderr: %s\n", run.Stdout, run.Stderr)
+	}
+	response.DrainCommandDone = true
+	return response, nil
+}
+
+func main() {
+	lambda.Start(runDrainCommand)
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/otterley/ec2-autoscaling-lifecycle-helpers/internal"
+	"github.com/pkg/errors"
+)
+
+// runDrainPlan advances the drain plan as far as it can on this poll
+// iteration.  Progress is kept in DrainProgress, so each invocation picks up
+// where the last one left off.
+func runDrainPlan(ctx context.Context, request internal.DrainParameters) (internal.DrainParameters, error) {
+	response := request
+	if len(request.DrainPlan) == 0 {
+		return response, nil
+	}
+
+	opCtx, cancel := internal.WithSafetyMargin(ctx)
+	defer cancel()
+
+	sess := session.Must(session.NewSession())
+	concurrency := request.StopConcurrency
+	if concurrency < 1 {
+		concurrency = internal.DefaultStopConcurrency
+	}
+
+	for len(response.DrainProgress) < len(response.DrainPlan) {
+		response.DrainProgress = append(response.DrainProgress,
+			internal.DrainStageStatus{Name: response.DrainPlan[len(response.DrainProgress)].Name})
+	}
+
+	for i, stage := range response.DrainPlan {
+		status := &response.DrainProgress[i]
+		if status.CompletedAt != "" {
+			continue
+		}
+
+		now := time.Now()
+		if status.StartedAt == "" {
+			fmt.Printf("Starting drain stage %s\n", stage.Name)
+			status.StartedAt = now.Format(time.RFC3339)
+		}
+
+		if status.TasksStoppedAt == "" {
+			startedAt, err := time.Parse(time.RFC3339, status.StartedAt)
+			if err != nil {
+				return response, errors.WithMessage(err, "time.Parse")
+			}
+			if now.Before(startedAt.Add(duration(stage.Delay))) {
+				fmt.Printf("Drain stage %s: waiting until %s to stop tasks\n",
+					stage.Name, startedAt.Add(duration(stage.Delay)).Format(time.RFC3339))
+				return response, nil
+			}
+
+			report, err := internal.StopMatchingTasks(opCtx, sess, request.ECSCluster, request.ECSInstanceID, concurrency, &stage.Selector)
+			status.TaskStops.Merge(report)
+			fmt.Printf("Drain stage %s task stops: %s\n", stage.Name, report)
+			if err != nil {
+				if internal.OutOfTime(opCtx) {
+					// Tasks already stopped won't be listed again, so the
+					// next iteration resumes where this one left off.
+					fmt.Printf("Ran out of time during drain stage %s; will resume\n", stage.Name)
+					return response, nil
+				}
+				return response, errors.WithMessage(err, "StopMatchingTasks")
+			}
+			status.TasksStoppedAt = now.Format(time.RFC3339)
+		} else if len(status.TaskStops.Protected) > 0 {
+			// Try again, in case their protection has since ended
+			report, err := internal.StopMatchingTasks(opCtx, sess, request.ECSCluster, request.ECSInstanceID, concurrency, &stage.Selector)
+			status.TaskStops.Merge(report)
+			fmt.Printf("Drain stage %s protected task stops: %s\n", stage.Name, report)
+			if err != nil {
+				if internal.OutOfTime(opCtx) {
+					return response, nil
+				}
+				return response, errors.WithMessage(err, "StopMatchingTasks")
+			}
+		}
+
+		remaining, err := internal.CountMatchingTasks(opCtx, sess, request.ECSCluster, request.ECSInstanceID, &stage.Selector)
+		if err != nil {
+			if internal.OutOfTime(opCtx) {
+				return response, nil
+			}
+			return response, errors.WithMessage(err, "CountMatchingTasks")
+		}
+		status.RemainingTasks = remaining
+		fmt.Printf("Drain stage %s: %d tasks remaining\n", stage.Name, remaining)
+
+		if remaining > 0 {
+			stoppedAt, err := time.Parse(time.RFC3339, status.TasksStoppedAt)
+			if err != nil {
+				return response, errors.WithMessage(err, "time.Parse")
+			}
+			if stage.Timeout == "" || now.Before(stoppedAt.Add(duration(stage.Timeout))) {
+				return response, nil
+			}
+			fmt.Printf("Drain stage %s timed out\n", stage.Name)
+			status.TimedOut = true
+		}
+		status.CompletedAt = now.Format(time.RFC3339)
+		fmt.Printf("Completed drain stage %s\n", stage.Name)
+	}
+
+	return response, nil
+}
+
+// duration parses a Go duration string that was validated by
+// internal.ParseDrainPlan.  An empty string is treated as zero.
+func duration(s string) time.Duration {
+	d, _ := time.ParseDuration(s)
+	return d
+}
+
+func main() {
+	lambda.Start(runDrainPlan)
+}
@@ -0,0 +1,112 @@
+// signal-ready is run on an instance, typically from its user data, to report
+// its readiness to the ready_callback Step Function waiting on it.  If a
+// command is given, the instance is ready if it exits successfully;
+// otherwise it's ready unless -fail is given.
+//
+//	signal-ready [-prefix path] [-wait duration] [-fail] [-reason text] [command [args...]]
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/ec2metadata"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/sfn"
+	"github.com/otterley/ec2-autoscaling-lifecycle-helpers/internal"
+	"github.com/pkg/errors"
+)
+
+const tokenPollInterval = 5 * time.Second
+
+func signalReady(ctx context.Context, prefix string, result internal.CallbackResult) error {
+	sess := session.Must(session.NewSession())
+	identity, err := ec2metadata.New(sess).GetInstanceIdentityDocument()
+	if err != nil {
+		return errors.WithMessage(err, "GetInstanceIdentityDocument")
+	}
+	sess = session.Must(session.NewSession(aws.NewConfig().WithRegion(identity.Region)))
+
+	// The token isn't published until the execution reaches the task that
+	// waits for it, which may be some time after the instance boots.
+	name := internal.TokenParameterName(prefix, identity.InstanceID)
+	var token string
+	for {
+		token, err = internal.GetTaskToken(ctx, sess, name)
+		if err != nil {
+			return err
+		}
+		if token != "" {
+			break
+		}
+		fmt.Printf("Waiting for task token %s\n", name)
+		select {
+		case <-ctx.Done():
+			return errors.WithMessage(ctx.Err(), "no task token published")
+		case <-time.After(tokenPollInterval):
+		}
+	}
+
+	client := sfn.New(sess)
+	if !result.Ready {
+		_, err = client.SendTaskFailureWithContext(ctx, &sfn.SendTaskFailureInput{
+			TaskToken: aws.String(token),
+			Error:     aws.String("InstanceNotReady"),
+			Cause:     aws.String(result.Reason),
+		})
+		return errors.WithMessage(err, "SendTaskFailure")
+	}
+	output, err := json.Marshal(result)
+	if err != nil {
+		return errors.WithMessage(err, "Error marshaling JSON")
+	}
+	_, err = client.SendTaskSuccessWithContext(ctx, &sfn.SendTaskSuccessInput{
+		TaskToken: aws.String(token),
+		Output:    aws.String(string(output)),
+	})
+	return errors.WithMessage(err, "SendTaskSuccess")
+}
+
+// runCheck runs the command, and reports the instance ready if it succeeds.
+func runCheck(args []string) internal.CallbackResult {
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return internal.CallbackResult{
+			Reason: fmt.Sprintf("%s: %v", strings.Join(args, " "), err),
+		}
+	}
+	return internal.CallbackResult{Ready: true}
+}
+
+func main() {
+	prefix := flag.String("prefix", internal.DefaultTokenParameterPrefix, "SSM parameter path under which task tokens are published")
+	wait := flag.Duration("wait", 10*time.Minute, "how long to wait for the task token to be published")
+	fail := flag.Bool("fail", false, "report the instance as not ready")
+	reason := flag.String("reason", "", "reason to report with the result")
+	flag.Parse()
+
+	result := internal.CallbackResult{Ready: !*fail, Reason: *reason}
+	if flag.NArg() > 0 {
+		result = runCheck(flag.Args())
+		if *reason != "" {
+			result.Reason = *reason
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), *wait)
+	defer cancel()
+	if err := signalReady(ctx, *prefix, result); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	fmt.Printf("Signaled ready=%t for this instance\n", result.Ready)
+}
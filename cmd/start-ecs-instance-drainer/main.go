@@ -1,23 +1,20 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"os"
-	"strings"
+	"strconv"
 	"time"
 
 	"github.com/aws/aws-lambda-go/lambda"
-	"github.com/aws/aws-sdk-go/aws"
-	"github.com/aws/aws-sdk-go/aws/client"
 	"github.com/aws/aws-sdk-go/aws/session"
-	"github.com/aws/aws-sdk-go/service/ecs"
-	"github.com/aws/aws-sdk-go/service/sfn"
 	"github.com/otterley/ec2-autoscaling-lifecycle-helpers/internal"
 	"github.com/pkg/errors"
 )
 
-func startECSInstanceDrainer(event internal.CloudwatchLifecycleEvent) error {
+func startECSInstanceDrainer(ctx context.Context, event internal.CloudwatchLifecycleEvent) error {
 	var err error
 
 	params := internal.DrainParameters{}
@@ -32,8 +29,8 @@ func startECSInstanceDrainer(event internal.CloudwatchLifecycleEvent) error {
 		return errors.New("STATE_MACHINE_ARN environment variable not defined")
 	}
 
-	params.ECSCluster = os.Getenv("ECS_CLUSTER")
-	if params.ECSCluster == "" {
+	params.ECSClusters = internal.EnvList("ECS_CLUSTER")
+	if len(params.ECSClusters) == 0 {
 		return errors.New("ECS_CLUSTER environment variable not defined")
 	}
 
@@ -46,60 +43,107 @@ func startECSInstanceDrainer(event internal.CloudwatchLifecycleEvent) error {
 	}
 	params.Deadline = time.Now().Add(timeout).Format(time.RFC3339)
 
-	sess := session.Must(session.NewSession())
-	params.ECSInstanceID, err = internal.GetECSInstanceARN(sess, params.ECSCluster, params.EC2InstanceID)
+	concurrency := internal.DefaultStopConcurrency
+	if os.Getenv("STOP_TASK_CONCURRENCY") != "" {
+		concurrency, err = strconv.Atoi(os.Getenv("STOP_TASK_CONCURRENCY"))
+		if err != nil {
+			return fmt.Errorf("Failed to parse STOP_TASK_CONCURRENCY: %v", err)
+		}
+		if concurrency < 1 {
+			return errors.New("STOP_TASK_CONCURRENCY must be at least 1")
+		}
+	}
+	params.StopConcurrency = concurrency
+
+	params.DrainPlan, err = internal.ParseDrainPlan(os.Getenv("DRAIN_PLAN"))
 	if err != nil {
-		return errors.WithMessage(err, "GetECSInstanceARN")
+		return errors.WithMessage(err, "DRAIN_PLAN")
 	}
-	if params.ECSInstanceID == "" {
-		return fmt.Errorf("No ECS instance matching EC2 instance ID %s found in cluster %s", params.EC2InstanceID, params.ECSCluster)
+
+	params.IgnoreDaemonServiceTasks = internal.EnvBool("IGNORE_DAEMON_SERVICE_TASKS", false)
+	params.IgnoredTaskFamilies = internal.EnvList("IGNORED_TASK_FAMILIES")
+	params.WaitForServiceReplacements = internal.EnvBool("WAIT_FOR_SERVICE_REPLACEMENTS", false)
+	params.CheckCapacity = internal.EnvBool("CHECK_CAPACITY", false)
+	params.ScaleOutForCapacity = internal.EnvBool("SCALE_OUT_FOR_CAPACITY", false)
+	params.OverProvision = internal.EnvBool("OVERPROVISION_SERVICES", false)
+	params.DeregisterInstance = internal.EnvBool("DEREGISTER_CONTAINER_INSTANCE", false)
+	params.ForceDeregister = internal.EnvBool("FORCE_DEREGISTER", false)
+	params.WaitForAgent = internal.EnvBool("WAIT_FOR_LIFECYCLE_AGENT", false)
+
+	params.DrainCommand, err = internal.ParseCommandSpec(os.Getenv("DRAIN_COMMAND"))
+	if err != nil {
+		return errors.WithMessage(err, "DRAIN_COMMAND")
 	}
 
-	fmt.Printf("Setting ECS instance %s on cluster %s to DRAINING state\n", params.ECSInstanceID, params.ECSCluster)
+	params.StopSelector, err = stopSelector()
+	if err != nil {
+		return err
+	}
+	params.CountSelector, err = internal.ParseTaskSelector(os.Getenv("COUNT_TASK_SELECTOR"))
+	if err != nil {
+		return errors.WithMessage(err, "COUNT_TASK_SELECTOR")
+	}
+
+	// Leave enough time to start the Step Function execution, or to report
+	// that we were interrupted, before the Lambda deadline.
+	opCtx, cancel := internal.WithSafetyMargin(ctx)
+	defer cancel()
 
-	ecsClient := ecs.New(sess)
-	if _, err := ecsClient.UpdateContainerInstancesState(
-		&ecs.UpdateContainerInstancesStateInput{
-			Cluster:            aws.String(params.ECSCluster),
-			ContainerInstances: aws.StringSlice([]string{params.ECSInstanceID}),
-			Status:             aws.String("DRAINING"),
-		},
-	); err != nil {
-		return errors.WithMessage(err, "UpdateContainerInstancesState")
+	sess := session.Must(session.NewSession())
+	params.ECSCluster, params.ECSInstanceID, err = internal.FindECSInstance(opCtx, sess, params.ECSClusters, params.EC2InstanceID)
+	if err != nil {
+		return errors.WithMessage(err, "FindECSInstance")
+	}
+	if params.ECSInstanceID == "" {
+		return fmt.Errorf("No ECS instance matching EC2 instance ID %s found in clusters %v", params.EC2InstanceID, params.ECSClusters)
 	}
+	fmt.Printf("Found ECS instance %s in cluster %s\n", params.ECSInstanceID, params.ECSCluster)
 
-	switch strings.ToLower(os.Getenv("STOP_ALL_NON_SERVICE_TASKS")) {
-	case "1", "true", "t", "yes", "y":
-		fmt.Printf("Stopping all non-service tasks on ECS instance %s in cluster %s\n", params.ECSInstanceID, params.ECSCluster)
-		if err := stopAllNonServiceTasks(sess, params.ECSCluster, params.ECSInstanceID); err != nil {
-			return errors.WithMessage(err, "stopAllNonServiceTasks")
+	// This may be a retry of an invocation that got further
+	executionName := internal.ExecutionName(params.AutoScalingLifecycleEvent)
+	exists, err := internal.ExecutionExists(opCtx, sess, params.StateMachineARN, executionName)
+	if err != nil {
+		return errors.WithMessage(err, "ExecutionExists")
+	}
+	if exists {
+		fmt.Printf("Execution %s is already draining EC2 instance %s\n", executionName, params.EC2InstanceID)
+		return nil
+	}
+	status, marker, err := internal.DrainMarker(opCtx, sess, params.ECSCluster, params.ECSInstanceID)
+	if err != nil {
+		return errors.WithMessage(err, "DrainMarker")
+	}
+	// An earlier invocation drained the instance but didn't start the
+	// execution, so only the state the execution needs is restored.
+	resumed := status == "DRAINING" && marker != "" && marker == params.LifecycleActionToken
+	if resumed {
+		fmt.Printf("ECS instance %s was already drained for this lifecycle action; resuming\n", params.ECSInstanceID)
+		if err := internal.ResumeDrain(opCtx, sess, &params); err != nil {
+			return errors.WithMessage(err, "ResumeDrain")
 		}
 	}
 
-	groups := strings.Split(os.Getenv("STOP_TASK_GROUPS"), ",")
-	if len(groups) > 0 {
-		fmt.Printf("Stopping tasks in groups %s on ECS instance %s in cluster %s\n", os.Getenv("STOP_TASK_GROUPS"), params.ECSInstanceID, params.ECSCluster)
-		if err := stopTaskGroups(sess, params.ECSCluster, params.ECSInstanceID, groups); err != nil {
-			return errors.WithMessage(err, "stopTaskGroups")
+	// Raised desired counts must be recorded in the execution input before
+	// they can safely be restored, so over-provisioning is left to the
+	// execution, as is draining after a drain command.
+	if !params.OverProvision && params.DrainCommand == nil && !resumed {
+		if err := internal.DrainIfCapacityAvailable(opCtx, sess, &params); err != nil {
+			return errors.WithMessage(interrupted(opCtx, err, params.TaskStops), "DrainIfCapacityAvailable")
 		}
 	}
 
-	startTime := time.Now()
-	executionName := startTime.Format("20060102T150405Z0700")
-
 	sfnInput, err := json.Marshal(params)
 	if err != nil {
 		return errors.WithMessage(err, "Error marshaling JSON")
 	}
 
-	sfnClient := sfn.New(sess)
-	_, err = sfnClient.StartExecution(&sfn.StartExecutionInput{
-		Name:            aws.String(executionName),
-		StateMachineArn: aws.String(params.StateMachineARN),
-		Input:           aws.String(string(sfnInput)),
-	})
+	started, err := internal.StartExecution(ctx, sess, params.StateMachineARN, executionName, sfnInput)
 	if err != nil {
-		return errors.WithMessage(err, "StartExecution")
+		return err
+	}
+	if !started {
+		fmt.Printf("Execution %s is already draining EC2 instance %s\n", executionName, params.EC2InstanceID)
+		return nil
 	}
 
 	fmt.Printf("Started Step Function %s with execution name %s\n", params.StateMachineARN, executionName)
@@ -107,63 +151,47 @@ func startECSInstanceDrainer(event internal.CloudwatchLifecycleEvent) error {
 	return nil
 }
 
-func stopAllNonServiceTasks(sess client.ConfigProvider, cluster, ecsInstanceID string) error {
-	return stopMatchingTasks(sess, cluster, ecsInstanceID,
-		func(task *ecs.Task) bool {
-			return !strings.HasPrefix(aws.StringValue(task.Group), "service:")
-		})
+// interrupted annotates err if it was caused by running out of time.  Returning
+// an error causes the Lambda invocation to be retried, and since draining is
+// idempotent and tasks that were already stopped are no longer listed, the
+// retry resumes where this one left off.
+func interrupted(ctx context.Context, err error, report internal.TaskStopReport) error {
+	if internal.OutOfTime(ctx) {
+		fmt.Printf("Ran out of time while draining (task stops: %s); the invocation will be retried\n", report)
+		return errors.WithMessage(err, "interrupted before Lambda deadline")
+	}
+	return err
 }
 
-func stopTaskGroups(sess client.ConfigProvider, cluster, ecsInstanceID string, taskGroups []string) error {
-	return stopMatchingTasks(sess, cluster, ecsInstanceID,
-		func(task *ecs.Task) bool {
-			for _, group := range taskGroups {
-				if aws.StringValue(task.Group) == group {
-					return true
-				}
-			}
-			return false
-		})
-}
+// stopSelector returns the selector for tasks to be stopped immediately.  It
+// combines STOP_TASK_SELECTOR with the simpler STOP_ALL_NON_SERVICE_TASKS and
+// STOP_TASK_GROUPS settings, each of which adds an Include rule.  The groups
+// in STOP_TASK_GROUPS are matched literally.  It returns nil if no tasks are
+// to be stopped.
+func stopSelector() (*internal.TaskSelector, error) {
+	selector, err := internal.ParseTaskSelector(os.Getenv("STOP_TASK_SELECTOR"))
+	if err != nil {
+		return nil, errors.WithMessage(err, "STOP_TASK_SELECTOR")
+	}
+	if selector == nil {
+		selector = &internal.TaskSelector{}
+	}
 
-func stopMatchingTasks(sess client.ConfigProvider, cluster, ecsInstanceID string, matchFunc func(*ecs.Task) bool) error {
-	var innerErr error
-	client := ecs.New(sess)
-	if err := client.ListTasksPages(
-		&ecs.ListTasksInput{
-			Cluster:           aws.String(cluster),
-			ContainerInstance: aws.String(ecsInstanceID),
-		},
-		func(page *ecs.ListTasksOutput, lastPage bool) bool {
-			tasks, innerErr := client.DescribeTasks(
-				&ecs.DescribeTasksInput{
-					Cluster: aws.String(cluster),
-					Tasks:   page.TaskArns,
-				},
-			)
-			if innerErr != nil {
-				return false // abandon ship
-			}
-			for _, task := range tasks.Tasks {
-				if matchFunc(task) {
-					_, innerErr = client.StopTask(
-						&ecs.StopTaskInput{
-							Cluster: aws.String(cluster),
-							Task:    task.TaskArn,
-							Reason:  aws.String("ECS instance drainer requested stop"),
-						},
-					)
-					if innerErr != nil {
-						return false
-					}
-				}
-			}
-			return !lastPage
-		},
-	); err != nil {
-		return err
+	if internal.EnvBool("STOP_ALL_NON_SERVICE_TASKS", false) {
+		selector.Include = append(selector.Include, internal.TaskRule{Group: "!service:*"})
+	}
+
+	for _, group := range internal.EnvList("STOP_TASK_GROUPS") {
+		selector.Include = append(selector.Include, internal.TaskRule{Group: internal.QuotePattern(group)})
+	}
+
+	if len(selector.Include) == 0 {
+		return nil, nil
+	}
+	if err := selector.Validate(); err != nil {
+		return nil, errors.WithMessage(err, "STOP_TASK_GROUPS")
 	}
-	return innerErr
+	return selector, nil
 }
 
 func main() {
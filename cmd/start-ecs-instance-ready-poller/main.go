@@ -1,10 +1,10 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"os"
-	"strings"
 	"time"
 
 	"github.com/aws/aws-lambda-go/lambda"
@@ -15,7 +15,7 @@ import (
 	"github.com/pkg/errors"
 )
 
-func startECSInstancePoller(event internal.CloudwatchLifecycleEvent) error {
+func startECSInstancePoller(ctx context.Context, event internal.CloudwatchLifecycleEvent) error {
 	var err error
 
 	params := internal.ECSReadyParameters{}
@@ -30,10 +30,15 @@ func startECSInstancePoller(event internal.CloudwatchLifecycleEvent) error {
 		return errors.New("STATE_MACHINE_ARN environment variable not defined")
 	}
 
-	params.ECSCluster = os.Getenv("ECS_CLUSTER")
-	if params.ECSCluster == "" {
+	// The instance may not have joined its cluster yet, so the cluster is
+	// discovered by the readiness check unless there's only one.
+	params.ECSClusters = internal.EnvList("ECS_CLUSTER")
+	if len(params.ECSClusters) == 0 {
 		return errors.New("ECS_CLUSTER environment variable not defined")
 	}
+	if len(params.ECSClusters) == 1 && params.ECSClusters[0] != internal.AllClusters {
+		params.ECSCluster = params.ECSClusters[0]
+	}
 
 	var timeout time.Duration
 	if os.Getenv("TIMEOUT") != "" {
@@ -46,7 +51,21 @@ func startECSInstancePoller(event internal.CloudwatchLifecycleEvent) error {
 
 	sess := session.Must(session.NewSession())
 
-	params.RequiredTaskFamilies = strings.Split(os.Getenv("REQUIRED_TASK_FAMILIES"), ",")
+	params.RequiredTaskFamilies = internal.EnvList("REQUIRED_TASK_FAMILIES")
+	params.Readiness, err = internal.ParseReadinessSpec(os.Getenv("READINESS_SPEC"))
+	if err != nil {
+		return errors.WithMessage(err, "READINESS_SPEC")
+	}
+
+	params.Quarantine, err = internal.ParseQuarantine(os.Getenv("QUARANTINE"))
+	if err != nil {
+		return errors.WithMessage(err, "QUARANTINE")
+	}
+	if err := internal.ValidateQuarantine(params.Quarantine, params.RequiredTaskFamilies, params.Readiness); err != nil {
+		return errors.WithMessage(err, "QUARANTINE")
+	}
+
+	params.CheckBaseline = internal.EnvBool("CHECK_BASELINE", false)
 
 	startTime := time.Now()
 	executionName := startTime.Format("20060102T150405Z0700")
@@ -57,7 +76,7 @@ func startECSInstancePoller(event internal.CloudwatchLifecycleEvent) error {
 	}
 
 	sfnClient := sfn.New(sess)
-	if _, err := sfnClient.StartExecution(&sfn.StartExecutionInput{
+	if _, err := sfnClient.StartExecutionWithContext(ctx, &sfn.StartExecutionInput{
 		Name:            aws.String(executionName),
 		StateMachineArn: aws.String(params.StateMachineARN),
 		Input:           aws.String(string(sfnInput)),
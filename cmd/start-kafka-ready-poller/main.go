@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"os"
@@ -9,15 +10,13 @@ import (
 
 	"github.com/aws/aws-lambda-go/lambda"
 	"github.com/aws/aws-sdk-go/aws"
-	"github.com/aws/aws-sdk-go/aws/client"
 	"github.com/aws/aws-sdk-go/aws/session"
-	"github.com/aws/aws-sdk-go/service/ec2"
 	"github.com/aws/aws-sdk-go/service/sfn"
 	"github.com/otterley/ec2-autoscaling-lifecycle-helpers/internal"
 	"github.com/pkg/errors"
 )
 
-func startKafkaPoller(event internal.CloudwatchLifecycleEvent) error {
+func startKafkaPoller(ctx context.Context, event internal.CloudwatchLifecycleEvent) error {
 	var err error
 
 	params := internal.KafkaReadyParameters{}
@@ -54,11 +53,13 @@ func startKafkaPoller(event internal.CloudwatchLifecycleEvent) error {
 	}
 	params.Deadline = time.Now().Add(timeout).Format(time.RFC3339)
 
+	params.CheckBaseline = internal.EnvBool("CHECK_BASELINE", false)
+
 	sess := session.Must(session.NewSession())
 
-	params.InternalIPAddr, err = getInternalAddr(sess, params.EC2InstanceID)
+	params.InternalIPAddr, err = internal.InstancePrivateIP(ctx, sess, params.EC2InstanceID)
 	if err != nil {
-		return errors.WithMessage(err, "getInternalAddr")
+		return errors.WithMessage(err, "InstancePrivateIP")
 	}
 
 	startTime := time.Now()
@@ -70,7 +71,7 @@ func startKafkaPoller(event internal.CloudwatchLifecycleEvent) error {
 	}
 
 	sfnClient := sfn.New(sess)
-	if _, err := sfnClient.StartExecution(&sfn.StartExecutionInput{
+	if _, err := sfnClient.StartExecutionWithContext(ctx, &sfn.StartExecutionInput{
 		Name:            aws.String(executionName),
 		StateMachineArn: aws.String(params.StateMachineARN),
 		Input:           aws.String(string(sfnInput)),
@@ -83,25 +84,6 @@ func startKafkaPoller(event internal.CloudwatchLifecycleEvent) error {
 	return nil
 }
 
-func getInternalAddr(sess client.ConfigProvider, ec2InstanceID string) (string, error) {
-	ec2Client := ec2.New(sess)
-	result, err := ec2Client.DescribeInstances(
-		&ec2.DescribeInstancesInput{
-			InstanceIds: aws.StringSlice([]string{ec2InstanceID}),
-		},
-	)
-	if err != nil {
-		return "", errors.WithMessage(err, "DescribeInstances")
-	}
-	if len(result.Reservations) != 1 {
-		return "", errors.New("assertion failure: reservation count != 1")
-	}
-	if len(result.Reservations[0].Instances) != 1 {
-		return "", errors.New("assertion failure: instance count != 1")
-	}
-	return aws.StringValue(result.Reservations[0].Instances[0].PrivateIpAddress), nil
-}
-
 func main() {
 	lambda.Start(startKafkaPoller)
 }
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/otterley/ec2-autoscaling-lifecycle-helpers/internal"
+	"github.com/pkg/errors"
+)
+
+func startLBDrainer(ctx context.Context, event internal.CloudwatchLifecycleEvent) error {
+	var err error
+
+	params := internal.LBDrainParameters{}
+	params.AutoScalingGroupName = event.Detail.AutoScalingGroupName
+	params.EC2InstanceID = event.Detail.EC2InstanceID
+	params.LifecycleActionToken = event.Detail.LifecycleActionToken
+	params.LifecycleHookName = event.Detail.LifecycleHookName
+	params.LifecycleTransition = event.Detail.LifecycleTransition
+
+	params.StateMachineARN = os.Getenv("STATE_MACHINE_ARN")
+	if params.StateMachineARN == "" {
+		return errors.New("STATE_MACHINE_ARN environment variable not defined")
+	}
+
+	var timeout time.Duration
+	if os.Getenv("TIMEOUT") != "" {
+		timeout, err = time.ParseDuration(os.Getenv("TIMEOUT"))
+		if err != nil {
+			return err
+		}
+	}
+	params.Deadline = time.Now().Add(timeout).Format(time.RFC3339)
+
+	params.TargetGroupARNs = internal.EnvList("TARGET_GROUP_ARNS")
+	params.LoadBalancerNames = internal.EnvList("LOAD_BALANCER_NAMES")
+	params.WaitForAgent = internal.EnvBool("WAIT_FOR_LIFECYCLE_AGENT", false)
+
+	opCtx, cancel := internal.WithSafetyMargin(ctx)
+	defer cancel()
+
+	sess := session.Must(session.NewSession())
+
+	if internal.EnvBool("DISCOVER_LOAD_BALANCERS", true) {
+		targetGroups, loadBalancers, err := internal.AutoScalingGroupLoadBalancers(opCtx, sess, params.AutoScalingGroupName)
+		if err != nil {
+			return errors.WithMessage(err, "AutoScalingGroupLoadBalancers")
+		}
+		params.TargetGroupARNs = internal.AppendMissing(params.TargetGroupARNs, targetGroups)
+		params.LoadBalancerNames = internal.AppendMissing(params.LoadBalancerNames, loadBalancers)
+	}
+
+	// This may be a retry of an invocation that got further
+	executionName := internal.ExecutionName(params.AutoScalingLifecycleEvent)
+	exists, err := internal.ExecutionExists(opCtx, sess, params.StateMachineARN, executionName)
+	if err != nil {
+		return errors.WithMessage(err, "ExecutionExists")
+	}
+	if exists {
+		fmt.Printf("Execution %s is already draining EC2 instance %s\n", executionName, params.EC2InstanceID)
+		return nil
+	}
+
+	// Deregistering is idempotent, so a retry simply repeats it
+	for _, arn := range params.TargetGroupARNs {
+		deregistered, err := internal.DeregisterInstanceTargets(opCtx, sess, arn, params.EC2InstanceID)
+		if err != nil {
+			return errors.WithMessage(err, arn)
+		}
+		if deregistered {
+			fmt.Printf("Deregistered EC2 instance %s from target group %s\n", params.EC2InstanceID, arn)
+		}
+	}
+	for _, name := range params.LoadBalancerNames {
+		if err := internal.DeregisterInstanceFromLoadBalancer(opCtx, sess, name, params.EC2InstanceID); err != nil {
+			return errors.WithMessage(err, name)
+		}
+		fmt.Printf("Deregistered EC2 instance %s from load balancer %s\n", params.EC2InstanceID, name)
+	}
+	params.Deregistered = true
+
+	sfnInput, err := json.Marshal(params)
+	if err != nil {
+		return errors.WithMessage(err, "Error marshaling JSON")
+	}
+
+	started, err := internal.StartExecution(ctx, sess, params.StateMachineARN, executionName, sfnInput)
+	if err != nil {
+		return err
+	}
+	if !started {
+		fmt.Printf("Execution %s is already draining EC2 instance %s\n", executionName, params.EC2InstanceID)
+		return nil
+	}
+
+	fmt.Printf("Started Step Function %s with execution name %s\n", params.StateMachineARN, executionName)
+	fmt.Printf("Input:\n%s\n", sfnInput)
+	return nil
+}
+
+func main() {
+	lambda.Start(startLBDrainer)
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/sfn"
+	"github.com/otterley/ec2-autoscaling-lifecycle-helpers/internal"
+	"github.com/pkg/errors"
+)
+
+func startReadyCallback(ctx context.Context, event internal.CloudwatchLifecycleEvent) error {
+	var err error
+
+	params := internal.CallbackParameters{}
+	params.AutoScalingGroupName = event.Detail.AutoScalingGroupName
+	params.EC2InstanceID = event.Detail.EC2InstanceID
+	params.LifecycleActionToken = event.Detail.LifecycleActionToken
+	params.LifecycleHookName = event.Detail.LifecycleHookName
+	params.LifecycleTransition = event.Detail.LifecycleTransition
+
+	params.StateMachineARN = os.Getenv("STATE_MACHINE_ARN")
+	if params.StateMachineARN == "" {
+		return errors.New("STATE_MACHINE_ARN environment variable not defined")
+	}
+
+	prefix := os.Getenv("TOKEN_PARAMETER_PREFIX")
+	if prefix == "" {
+		prefix = internal.DefaultTokenParameterPrefix
+	}
+	params.TokenParameterName = internal.TokenParameterName(prefix, params.EC2InstanceID)
+
+	// The deadline is enforced by the heartbeat timeout of the task that
+	// waits for the instance's signal, which must be at least a second.
+	timeout, err := time.ParseDuration(os.Getenv("TIMEOUT"))
+	if err != nil {
+		return errors.WithMessage(err, "TIMEOUT")
+	}
+	if timeout < time.Second {
+		return errors.New("TIMEOUT must be at least 1s")
+	}
+	params.Deadline = time.Now().Add(timeout).Format(time.RFC3339)
+	params.TimeoutSeconds = int(timeout / time.Second)
+
+	startTime := time.Now()
+	executionName := startTime.Format("20060102T150405Z0700")
+
+	sfnInput, err := json.Marshal(params)
+	if err != nil {
+		return errors.WithMessage(err, "Error marshaling JSON")
+	}
+
+	sess := session.Must(session.NewSession())
+	sfnClient := sfn.New(sess)
+	if _, err := sfnClient.StartExecutionWithContext(ctx, &sfn.StartExecutionInput{
+		Name:            aws.String(executionName),
+		StateMachineArn: aws.String(params.StateMachineARN),
+		Input:           aws.String(string(sfnInput)),
+	}); err != nil {
+		return errors.WithMessage(err, "StartExecution")
+	}
+
+	fmt.Printf("Started Step Function %s with execution name %s\n", params.StateMachineARN, executionName)
+	fmt.Printf("Input:\n%s\n", sfnInput)
+	return nil
+}
+
+func main() {
+	lambda.Start(startReadyCallback)
+}
@@ -0,0 +1,70 @@
+package internal
+
+import (
+	"context"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/client"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/pkg/errors"
+)
+
+// The lifecycle agent on an instance reports the outcome of the hooks it ran
+// for a target lifecycle state by tagging the instance with AgentTagKey(state)
+// set to AgentDone or AgentFailed.
+const (
+	AgentTagPrefix = "lifecycle-agent:"
+	AgentDone      = "done"
+	AgentFailed    = "failed"
+)
+
+// AgentTagKey returns the key of the tag reporting the outcome of the
+// lifecycle agent's hooks for the target lifecycle state.
+func AgentTagKey(state string) string {
+	return AgentTagPrefix + state
+}
+
+// SetAgentStatus tags the EC2 instance with the outcome of the lifecycle
+// agent's hooks for the target lifecycle state.
+func SetAgentStatus(ctx context.Context, sess client.ConfigProvider, ec2InstanceID, state, status string) error {
+	_, err := ec2.New(sess).CreateTagsWithContext(
+		ctx,
+		&ec2.CreateTagsInput{
+			Resources: aws.StringSlice([]string{ec2InstanceID}),
+			Tags: []*ec2.Tag{
+				{
+					Key:   aws.String(AgentTagKey(state)),
+					Value: aws.String(status),
+				},
+			},
+		},
+	)
+	return errors.WithMessage(err, "CreateTags")
+}
+
+// AgentStatus returns the outcome the lifecycle agent reported for the target
+// lifecycle state, or an empty string if it hasn't reported one.
+func AgentStatus(ctx context.Context, sess client.ConfigProvider, ec2InstanceID, state string) (string, error) {
+	result, err := ec2.New(sess).DescribeTagsWithContext(
+		ctx,
+		&ec2.DescribeTagsInput{
+			Filters: []*ec2.Filter{
+				{
+					Name:   aws.String("resource-id"),
+					Values: aws.StringSlice([]string{ec2InstanceID}),
+				},
+				{
+					Name:   aws.String("key"),
+					Values: aws.StringSlice([]string{AgentTagKey(state)}),
+				},
+			},
+		},
+	)
+	if err != nil {
+		return "", errors.WithMessage(err, "DescribeTags")
+	}
+	if len(result.Tags) == 0 {
+		return "", nil
+	}
+	return aws.StringValue(result.Tags[0].Value), nil
+}
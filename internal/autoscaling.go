@@ -0,0 +1,68 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/client"
+	"github.com/aws/aws-sdk-go/service/autoscaling"
+	"github.com/pkg/errors"
+)
+
+// IncrementDesiredCapacity adds one instance to the Auto Scaling Group's
+// desired capacity, unless it's already at its maximum size.  It returns true
+// if the desired capacity was changed.
+func IncrementDesiredCapacity(ctx context.Context, sess client.ConfigProvider, autoScalingGroupName string) (bool, error) {
+	client := autoscaling.New(sess)
+	result, err := client.DescribeAutoScalingGroupsWithContext(
+		ctx,
+		&autoscaling.DescribeAutoScalingGroupsInput{
+			AutoScalingGroupNames: aws.StringSlice([]string{autoScalingGroupName}),
+		},
+	)
+	if err != nil {
+		return false, errors.WithMessage(err, "DescribeAutoScalingGroups")
+	}
+	if len(result.AutoScalingGroups) != 1 {
+		return false, errors.New("assertion failure: auto scaling group count != 1")
+	}
+	group := result.AutoScalingGroups[0]
+	desired := aws.Int64Value(group.DesiredCapacity)
+	if desired >= aws.Int64Value(group.MaxSize) {
+		fmt.Printf("Auto Scaling Group %s is already at its maximum size of %d\n", autoScalingGroupName, desired)
+		return false, nil
+	}
+
+	fmt.Printf("Increasing desired capacity of Auto Scaling Group %s to %d\n", autoScalingGroupName, desired+1)
+	if _, err := client.SetDesiredCapacityWithContext(
+		ctx,
+		&autoscaling.SetDesiredCapacityInput{
+			AutoScalingGroupName: aws.String(autoScalingGroupName),
+			DesiredCapacity:      aws.Int64(desired + 1),
+			HonorCooldown:        aws.Bool(false),
+		},
+	); err != nil {
+		return false, errors.WithMessage(err, "SetDesiredCapacity")
+	}
+	return true, nil
+}
+
+// AutoScalingGroupLoadBalancers returns the target groups and classic load
+// balancers attached to the Auto Scaling Group.
+func AutoScalingGroupLoadBalancers(ctx context.Context, sess client.ConfigProvider, autoScalingGroupName string) ([]string, []string, error) {
+	result, err := autoscaling.New(sess).DescribeAutoScalingGroupsWithContext(
+		ctx,
+		&autoscaling.DescribeAutoScalingGroupsInput{
+			AutoScalingGroupNames: aws.StringSlice([]string{autoScalingGroupName}),
+		},
+	)
+	if err != nil {
+		return nil, nil, errors.WithMessage(err, "DescribeAutoScalingGroups")
+	}
+	if len(result.AutoScalingGroups) != 1 {
+		return nil, nil, errors.New("assertion failure: auto scaling group count != 1")
+	}
+	group := result.AutoScalingGroups[0]
+	return aws.StringValueSlice(group.TargetGroupARNs), aws.StringValueSlice(group.LoadBalancerNames), nil
+}
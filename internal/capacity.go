@@ -0,0 +1,344 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/client"
+	"github.com/aws/aws-sdk-go/service/ecs"
+	"github.com/pkg/errors"
+)
+
+// taskDemand is the set of resources a task needs from a container instance.
+type taskDemand struct {
+	taskARN  string
+	service  string
+	cpu      int64
+	memory   int64
+	tcpPorts []string
+	udpPorts []string
+	distinct bool
+}
+
+func (d taskDemand) String() string {
+	return fmt.Sprintf("task %s of service %s (%d CPU units, %d MiB, TCP ports %v, UDP ports %v)",
+		d.taskARN, d.service, d.cpu, d.memory, d.tcpPorts, d.udpPorts)
+}
+
+// instanceSupply is the set of resources a container instance has left.
+type instanceSupply struct {
+	arn      string
+	cpu      int64
+	memory   int64
+	tcpPorts map[string]bool
+	udpPorts map[string]bool
+	services map[string]bool
+}
+
+func (s *instanceSupply) fits(d taskDemand) bool {
+	if s.cpu < d.cpu || s.memory < d.memory {
+		return false
+	}
+	if d.distinct && s.services[d.service] {
+		return false
+	}
+	for _, port := range d.tcpPorts {
+		if s.tcpPorts[port] {
+			return false
+		}
+	}
+	for _, port := range d.udpPorts {
+		if s.udpPorts[port] {
+			return false
+		}
+	}
+	return true
+}
+
+func (s *instanceSupply) place(d taskDemand) {
+	s.cpu -= d.cpu
+	s.memory -= d.memory
+	for _, port := range d.tcpPorts {
+		s.tcpPorts[port] = true
+	}
+	for _, port := range d.udpPorts {
+		s.udpPorts[port] = true
+	}
+	s.services[d.service] = true
+}
+
+// CapacityShortfall works out whether the cluster's other ACTIVE container
+// instances have enough CPU, memory and host ports left to absorb the service
+// tasks on the given instance, were it to be drained.  Only service tasks are
+// considered, since the scheduler doesn't replace standalone tasks, and
+// neither are tasks of DAEMON services.  Of the placement constraints, only
+// distinctInstance is honored; memberOf expressions aren't evaluated.
+//
+// It returns a description of each task that wouldn't fit anywhere, or an
+// empty slice if they all would.
+func CapacityShortfall(ctx context.Context, sess client.ConfigProvider, cluster, ecsInstanceID string) ([]string, error) {
+	demands, err := instanceDemands(ctx, sess, cluster, ecsInstanceID)
+	if err != nil {
+		return nil, err
+	}
+	if len(demands) == 0 {
+		return nil, nil
+	}
+
+	supplies, err := clusterSupplies(ctx, sess, cluster, ecsInstanceID, demands)
+	if err != nil {
+		return nil, err
+	}
+	return placeDemands(demands, supplies), nil
+}
+
+// placeDemands places each task on the first instance it fits, largest tasks
+// first, and returns a description of each task that doesn't fit anywhere.
+func placeDemands(demands []taskDemand, supplies []*instanceSupply) []string {
+	sort.Slice(demands, func(i, j int) bool {
+		if demands[i].memory != demands[j].memory {
+			return demands[i].memory > demands[j].memory
+		}
+		return demands[i].cpu > demands[j].cpu
+	})
+	var shortfall []string
+	for _, demand := range demands {
+		placed := false
+		for _, supply := range supplies {
+			if supply.fits(demand) {
+				supply.place(demand)
+				placed = true
+				break
+			}
+		}
+		if !placed {
+			shortfall = append(shortfall, demand.String())
+		}
+	}
+	return shortfall
+}
+
+// instanceDemands returns the resources needed by each non-daemon service task
+// on the container instance.
+func instanceDemands(ctx context.Context, sess client.ConfigProvider, cluster, ecsInstanceID string) ([]taskDemand, error) {
+	var tasks []*ecs.Task
+	if err := DescribeInstanceTasks(ctx, sess, cluster, ecsInstanceID,
+		func(page []*ecs.Task, failures []*ecs.Failure) bool {
+			for _, task := range page {
+				if TaskServiceName(task) != "" {
+					tasks = append(tasks, task)
+				}
+			}
+			return true
+		},
+	); err != nil {
+		return nil, err
+	}
+
+	var names []string
+	seen := make(map[string]bool)
+	for _, task := range tasks {
+		if name := TaskServiceName(task); !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+	services, _, err := DescribeServices(ctx, sess, cluster, names)
+	if err != nil {
+		return nil, err
+	}
+	daemon := make(map[string]bool)
+	distinct := make(map[string]bool)
+	for _, service := range services {
+		name := aws.StringValue(service.ServiceName)
+		daemon[name] = aws.StringValue(service.SchedulingStrategy) == "DAEMON"
+		for _, constraint := range service.PlacementConstraints {
+			if aws.StringValue(constraint.Type) == "distinctInstance" {
+				distinct[name] = true
+			}
+		}
+	}
+
+	client := ecs.New(sess)
+	taskDefinitions := make(map[string]*ecs.TaskDefinition)
+	var demands []taskDemand
+	for _, task := range tasks {
+		name := TaskServiceName(task)
+		if daemon[name] {
+			continue
+		}
+		arn := aws.StringValue(task.TaskDefinitionArn)
+		taskDefinition, ok := taskDefinitions[arn]
+		if !ok {
+			result, err := client.DescribeTaskDefinitionWithContext(
+				ctx,
+				&ecs.DescribeTaskDefinitionInput{
+					TaskDefinition: aws.String(arn),
+				},
+			)
+			if err != nil {
+				return nil, errors.WithMessage(err, "DescribeTaskDefinition")
+			}
+			taskDefinition = result.TaskDefinition
+			taskDefinitions[arn] = taskDefinition
+		}
+		demand := taskDemand{
+			taskARN:  aws.StringValue(task.TaskArn),
+			service:  name,
+			distinct: distinct[name],
+		}
+		for _, container := range taskDefinition.ContainerDefinitions {
+			demand.cpu += aws.Int64Value(container.Cpu)
+			// ECS reserves the soft limit if there is one
+			if container.MemoryReservation != nil {
+				demand.memory += aws.Int64Value(container.MemoryReservation)
+			} else {
+				demand.memory += aws.Int64Value(container.Memory)
+			}
+			if aws.StringValue(taskDefinition.NetworkMode) == "awsvpc" {
+				// Tasks get their own network interface
+				continue
+			}
+			for _, mapping := range container.PortMappings {
+				if aws.Int64Value(mapping.HostPort) == 0 {
+					// Dynamic host port
+					continue
+				}
+				port := strconv.FormatInt(aws.Int64Value(mapping.HostPort), 10)
+				if aws.StringValue(mapping.Protocol) == "udp" {
+					demand.udpPorts = append(demand.udpPorts, port)
+				} else {
+					demand.tcpPorts = append(demand.tcpPorts, port)
+				}
+			}
+		}
+		// Task-level sizes, when given, take precedence
+		if cpu, err := strconv.ParseInt(aws.StringValue(task.Cpu), 10, 64); err == nil {
+			demand.cpu = cpu
+		}
+		if memory, err := strconv.ParseInt(aws.StringValue(task.Memory), 10, 64); err == nil {
+			demand.memory = memory
+		}
+		demands = append(demands, demand)
+	}
+	return demands, nil
+}
+
+// clusterSupplies returns the resources remaining on each connected, ACTIVE
+// container instance in the cluster other than the one being drained.
+func clusterSupplies(ctx context.Context, sess client.ConfigProvider, cluster, ecsInstanceID string, demands []taskDemand) ([]*instanceSupply, error) {
+	var (
+		supplies []*instanceSupply
+		innerErr error
+	)
+	byARN := make(map[string]*instanceSupply)
+
+	client := ecs.New(sess)
+	if err := client.ListContainerInstancesPagesWithContext(
+		ctx,
+		&ecs.ListContainerInstancesInput{
+			Cluster: aws.String(cluster),
+			Status:  aws.String("ACTIVE"),
+		},
+		func(page *ecs.ListContainerInstancesOutput, lastPage bool) bool {
+			if len(page.ContainerInstanceArns) == 0 {
+				return false // nothing to do
+			}
+			var instances *ecs.DescribeContainerInstancesOutput
+			instances, innerErr = client.DescribeContainerInstancesWithContext(
+				ctx,
+				&ecs.DescribeContainerInstancesInput{
+					Cluster:            aws.String(cluster),
+					ContainerInstances: page.ContainerInstanceArns,
+				},
+			)
+			if innerErr != nil {
+				return false
+			}
+			for _, instance := range instances.ContainerInstances {
+				arn := aws.StringValue(instance.ContainerInstanceArn)
+				if arn == ecsInstanceID || !aws.BoolValue(instance.AgentConnected) {
+					continue
+				}
+				supply := &instanceSupply{
+					arn:      arn,
+					tcpPorts: make(map[string]bool),
+					udpPorts: make(map[string]bool),
+					services: make(map[string]bool),
+				}
+				for _, resource := range instance.RemainingResources {
+					switch aws.StringValue(resource.Name) {
+					case "CPU":
+						supply.cpu = aws.Int64Value(resource.IntegerValue)
+					case "MEMORY":
+						supply.memory = aws.Int64Value(resource.IntegerValue)
+					case "PORTS":
+						for _, port := range resource.StringSetValue {
+							supply.tcpPorts[aws.StringValue(port)] = true
+						}
+					case "PORTS_UDP":
+						for _, port := range resource.StringSetValue {
+							supply.udpPorts[aws.StringValue(port)] = true
+						}
+					}
+				}
+				supplies = append(supplies, supply)
+				byARN[arn] = supply
+			}
+			return !lastPage
+		},
+	); err != nil {
+		return nil, errors.WithMessage(err, "ListContainerInstances")
+	}
+	if innerErr != nil {
+		return nil, errors.WithMessage(innerErr, "DescribeContainerInstances")
+	}
+
+	// Note where tasks of distinctInstance services already run
+	seen := make(map[string]bool)
+	for _, demand := range demands {
+		if !demand.distinct || seen[demand.service] {
+			continue
+		}
+		seen[demand.service] = true
+		if err := client.ListTasksPagesWithContext(
+			ctx,
+			&ecs.ListTasksInput{
+				Cluster:     aws.String(cluster),
+				ServiceName: aws.String(demand.service),
+			},
+			func(page *ecs.ListTasksOutput, lastPage bool) bool {
+				if len(page.TaskArns) == 0 {
+					return false // nothing to do
+				}
+				var tasks *ecs.DescribeTasksOutput
+				tasks, innerErr = client.DescribeTasksWithContext(
+					ctx,
+					&ecs.DescribeTasksInput{
+						Cluster: aws.String(cluster),
+						Tasks:   page.TaskArns,
+					},
+				)
+				if innerErr != nil {
+					return false
+				}
+				for _, task := range tasks.Tasks {
+					if supply, ok := byARN[aws.StringValue(task.ContainerInstanceArn)]; ok {
+						supply.services[demand.service] = true
+					}
+				}
+				return !lastPage
+			},
+		); err != nil {
+			return nil, errors.WithMessage(err, "ListTasks")
+		}
+		if innerErr != nil {
+			return nil, errors.WithMessage(innerErr, "DescribeTasks")
+		}
+	}
+
+	return supplies, nil
+}
@@ -0,0 +1,118 @@
+package internal
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newSupply(arn string, cpu, memory int64, tcpPorts ...string) *instanceSupply {
+	supply := &instanceSupply{
+		arn:      arn,
+		cpu:      cpu,
+		memory:   memory,
+		tcpPorts: make(map[string]bool),
+		udpPorts: make(map[string]bool),
+		services: make(map[string]bool),
+	}
+	for _, port := range tcpPorts {
+		supply.tcpPorts[port] = true
+	}
+	return supply
+}
+
+func TestInstanceSupplyFits(t *testing.T) {
+	for _, test := range []struct {
+		name   string
+		demand taskDemand
+		want   bool
+	}{
+		{"fits", taskDemand{service: "web", cpu: 512, memory: 1024}, true},
+		{"exactly", taskDemand{service: "web", cpu: 1024, memory: 2048}, true},
+		{"too much CPU", taskDemand{service: "web", cpu: 1025, memory: 1024}, false},
+		{"too much memory", taskDemand{service: "web", cpu: 512, memory: 2049}, false},
+		{"free TCP port", taskDemand{service: "web", tcpPorts: []string{"8080"}}, true},
+		{"TCP port in use", taskDemand{service: "web", tcpPorts: []string{"80"}}, false},
+		{"UDP port in use", taskDemand{service: "dns", udpPorts: []string{"53"}}, false},
+		{"TCP port free for UDP", taskDemand{service: "web", udpPorts: []string{"80"}}, true},
+		{"distinct service already there", taskDemand{service: "api", distinct: true}, false},
+		{"other service already there", taskDemand{service: "web", distinct: true}, true},
+	} {
+		supply := newSupply("instance", 1024, 2048, "80")
+		supply.udpPorts["53"] = true
+		supply.services["api"] = true
+		assert.Equal(t, test.want, supply.fits(test.demand), test.name)
+	}
+}
+
+func TestInstanceSupplyPlace(t *testing.T) {
+	supply := newSupply("instance", 1024, 2048)
+	demand := taskDemand{service: "web", cpu: 256, memory: 512, tcpPorts: []string{"80"}, udpPorts: []string{"53"}, distinct: true}
+	assert.True(t, supply.fits(demand))
+	supply.place(demand)
+
+	assert.Equal(t, int64(768), supply.cpu)
+	assert.Equal(t, int64(1536), supply.memory)
+	assert.True(t, supply.tcpPorts["80"])
+	assert.True(t, supply.udpPorts["53"])
+	assert.True(t, supply.services["web"])
+	assert.False(t, supply.fits(demand))
+}
+
+func TestPlaceDemands(t *testing.T) {
+	for _, test := range []struct {
+		name      string
+		demands   []taskDemand
+		supplies  []*instanceSupply
+		shortfall []string
+	}{
+		{
+			name:     "no demands",
+			supplies: []*instanceSupply{newSupply("a", 1024, 1024)},
+		},
+		{
+			name: "spread over instances",
+			demands: []taskDemand{
+				{taskARN: "1", service: "web", cpu: 512, memory: 1024},
+				{taskARN: "2", service: "web", cpu: 512, memory: 1024},
+			},
+			supplies: []*instanceSupply{newSupply("a", 1024, 1024), newSupply("b", 1024, 1024)},
+		},
+		{
+			name: "largest first",
+			demands: []taskDemand{
+				{taskARN: "small", service: "web", cpu: 128, memory: 256},
+				{taskARN: "large", service: "web", cpu: 128, memory: 1024},
+			},
+			supplies: []*instanceSupply{newSupply("a", 1024, 1024), newSupply("b", 1024, 256)},
+		},
+		{
+			name: "no room",
+			demands: []taskDemand{
+				{taskARN: "1", service: "web", cpu: 512, memory: 1024},
+				{taskARN: "2", service: "web", cpu: 512, memory: 1024},
+			},
+			supplies:  []*instanceSupply{newSupply("a", 1024, 1024)},
+			shortfall: []string{"task 2 of service web (512 CPU units, 1024 MiB, TCP ports [], UDP ports [])"},
+		},
+		{
+			name: "distinct instances",
+			demands: []taskDemand{
+				{taskARN: "1", service: "api", distinct: true},
+				{taskARN: "2", service: "api", distinct: true},
+			},
+			supplies:  []*instanceSupply{newSupply("a", 1024, 1024)},
+			shortfall: []string{"task 2 of service api (0 CPU units, 0 MiB, TCP ports [], UDP ports [])"},
+		},
+		{
+			name: "host port taken",
+			demands: []taskDemand{
+				{taskARN: "1", service: "web", tcpPorts: []string{"80"}},
+			},
+			supplies:  []*instanceSupply{newSupply("a", 1024, 1024, "80")},
+			shortfall: []string{"task 1 of service web (0 CPU units, 0 MiB, TCP ports [80], UDP ports [])"},
+		},
+	} {
+		assert.Equal(t, test.shortfall, placeDemands(test.demands, test.supplies), test.name)
+	}
+}
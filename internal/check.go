@@ -0,0 +1,263 @@
+package internal
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Check is a readiness condition that's evaluated on each poll iteration.
+type Check interface {
+	Name() string
+	// Evaluate returns whether the condition holds.  Errors that may be
+	// transient should be reported as a not-ready Result rather than
+	// returned.
+	Evaluate(ctx context.Context, params *CheckParameters) (Result, error)
+}
+
+// StandaloneCheck is implemented by checks that can't be members of a
+// composite check, such as those that change the instance until they're
+// ready: a composite may stop evaluating them before then, or go on to
+// evaluate other members afterwards.
+type StandaloneCheck interface {
+	Standalone() bool
+}
+
+// Result is the outcome of evaluating a Check.
+type Result struct {
+	Name    string
+	Ready   bool
+	Reason  string   `json:",omitempty"`
+	Details []string `json:",omitempty"`
+	// Checks holds the results of a composite check's members.
+	Checks []Result `json:",omitempty"`
+}
+
+// CheckSpec configures a check.  Exactly one of Type, All and Any is given:
+// Type names a registered check, configured by Config; All and Any combine
+// other checks, requiring every one or at least one of them to be ready.
+//
+// Specs are configured as JSON, for example:
+//
+//	{
+//	  "All": [
+//	    {"Type": "ecs-instance", "Config": {"Clusters": ["web"]}},
+//	    {"Any": [
+//	      {"Name": "kafka-plain", "Type": "kafka", "Config": {"Port": 9092}},
+//	      {"Name": "kafka-tls", "Type": "kafka", "Config": {"Port": 9093}}
+//	    ]}
+//	  ]
+//	}
+//
+// A check's name defaults to its type, or to "all" or "any".  Names must be
+// unique, since checks keep their state under them.
+type CheckSpec struct {
+	Name   string          `json:",omitempty"`
+	Type   string          `json:",omitempty"`
+	Config json.RawMessage `json:",omitempty"`
+	All    []CheckSpec     `json:",omitempty"`
+	Any    []CheckSpec     `json:",omitempty"`
+}
+
+// CheckFactory builds a check of a registered type from its JSON
+// configuration, which is empty if none was given.
+type CheckFactory func(name string, config json.RawMessage) (Check, error)
+
+var checkFactories = make(map[string]CheckFactory)
+
+// RegisterCheck makes a check type available to CheckSpecs.  It's meant to be
+// called from init functions, and panics if the type is already registered.
+func RegisterCheck(typ string, factory CheckFactory) {
+	if _, ok := checkFactories[typ]; ok {
+		panic("check type registered twice: " + typ)
+	}
+	checkFactories[typ] = factory
+}
+
+// CheckTypes returns the registered check types.
+func CheckTypes() []string {
+	var types []string
+	for typ := range checkFactories {
+		types = append(types, typ)
+	}
+	sort.Strings(types)
+	return types
+}
+
+// ParseCheckSpec parses a JSON-encoded check spec and makes sure a check can
+// be built from it.
+func ParseCheckSpec(s string) (*CheckSpec, error) {
+	if strings.TrimSpace(s) == "" {
+		return nil, errors.New("empty check spec")
+	}
+	spec := &CheckSpec{}
+	dec := json.NewDecoder(bytes.NewBufferString(s))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(spec); err != nil {
+		return nil, errors.WithMessage(err, "invalid check spec")
+	}
+	if _, err := NewCheck(*spec); err != nil {
+		return nil, err
+	}
+	return spec, nil
+}
+
+// NewCheck builds the check configured by spec.
+func NewCheck(spec CheckSpec) (Check, error) {
+	return newCheck(spec, make(map[string]bool))
+}
+
+func newCheck(spec CheckSpec, names map[string]bool) (Check, error) {
+	kinds := 0
+	for _, given := range []bool{spec.Type != "", spec.All != nil, spec.Any != nil} {
+		if given {
+			kinds++
+		}
+	}
+	if kinds != 1 {
+		return nil, fmt.Errorf("check %q: exactly one of Type, All and Any is required", spec.Name)
+	}
+
+	name := spec.Name
+	switch {
+	case name != "":
+	case spec.Type != "":
+		name = spec.Type
+	case spec.All != nil:
+		name = "all"
+	default:
+		name = "any"
+	}
+	if names[name] {
+		return nil, fmt.Errorf("check %q: name is used more than once; give each a distinct Name", name)
+	}
+	names[name] = true
+
+	if spec.Type != "" {
+		factory, ok := checkFactories[spec.Type]
+		if !ok {
+			return nil, fmt.Errorf("check %q: unknown type %q (known types: %s)", name, spec.Type, strings.Join(CheckTypes(), ", "))
+		}
+		check, err := factory(name, spec.Config)
+		if err != nil {
+			return nil, errors.WithMessage(err, fmt.Sprintf("check %q", name))
+		}
+		return check, nil
+	}
+
+	members := spec.All
+	if spec.Any != nil {
+		members = spec.Any
+	}
+	if len(members) == 0 {
+		return nil, fmt.Errorf("check %q: no member checks", name)
+	}
+	composite := &compositeCheck{name: name, all: spec.All != nil}
+	for _, member := range members {
+		check, err := newCheck(member, names)
+		if err != nil {
+			return nil, err
+		}
+		if standalone, ok := check.(StandaloneCheck); ok && standalone.Standalone() {
+			return nil, fmt.Errorf("check %q: can't be a member of composite check %q", check.Name(), name)
+		}
+		composite.checks = append(composite.checks, check)
+	}
+	return composite, nil
+}
+
+// compositeCheck is ready if all of its checks are, or any of them is.  Its
+// checks are evaluated in order, stopping as soon as the outcome is known.
+type compositeCheck struct {
+	name   string
+	all    bool
+	checks []Check
+}
+
+func (c *compositeCheck) Name() string {
+	return c.name
+}
+
+func (c *compositeCheck) Evaluate(ctx context.Context, params *CheckParameters) (Result, error) {
+	result := Result{Name: c.name, Ready: c.all}
+	for _, check := range c.checks {
+		member, err := check.Evaluate(ctx, params)
+		if err != nil {
+			if OutOfTime(ctx) {
+				return result, err
+			}
+			member = Result{Name: check.Name(), Reason: err.Error()}
+		}
+		result.Checks = append(result.Checks, member)
+		if member.Ready != c.all {
+			result.Ready = member.Ready
+			break
+		}
+	}
+
+	var failing []string
+	for _, member := range result.Checks {
+		if !member.Ready {
+			failing = append(failing, member.Name)
+		}
+	}
+	switch {
+	case result.Ready:
+	case c.all:
+		result.Reason = "not ready: " + strings.Join(failing, ", ")
+	default:
+		result.Reason = "none ready: " + strings.Join(failing, ", ")
+	}
+	return result, nil
+}
+
+// LoadCheckState decodes the state a check saved on an earlier iteration into
+// v.  It leaves v alone if there is none.
+func LoadCheckState(params *CheckParameters, name string, v interface{}) error {
+	state, ok := params.CheckState[name]
+	if !ok {
+		return nil
+	}
+	return errors.WithMessage(json.Unmarshal(state, v), "LoadCheckState")
+}
+
+// SaveCheckState saves v as the check's state, to be loaded on the next
+// iteration.
+func SaveCheckState(params *CheckParameters, name string, v interface{}) error {
+	state, err := json.Marshal(v)
+	if err != nil {
+		return errors.WithMessage(err, "SaveCheckState")
+	}
+	if params.CheckState == nil {
+		params.CheckState = make(map[string]json.RawMessage)
+	}
+	params.CheckState[name] = state
+	return nil
+}
+
+// PrintResult logs a check result and those of its members.
+func PrintResult(result Result) {
+	printResult(result, "")
+}
+
+func printResult(result Result, indent string) {
+	status := "not ready"
+	if result.Ready {
+		status = "ready"
+	}
+	if result.Reason != "" {
+		status += ": " + result.Reason
+	}
+	fmt.Printf("%sCheck %s %s\n", indent, result.Name, status)
+	for _, detail := range result.Details {
+		fmt.Printf("%s  %s\n", indent, detail)
+	}
+	for _, member := range result.Checks {
+		printResult(member, indent+"  ")
+	}
+}
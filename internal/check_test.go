@@ -0,0 +1,154 @@
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+// fixedCheck is a check whose outcome is given by its configuration.
+type fixedCheck struct {
+	name   string
+	config struct {
+		Ready      bool
+		Error      string
+		Standalone bool
+	}
+}
+
+// evaluated records the names of the fixed checks evaluated, in order.
+var evaluated []string
+
+func init() {
+	RegisterCheck("fixed", func(name string, config json.RawMessage) (Check, error) {
+		check := &fixedCheck{name: name}
+		if len(config) > 0 {
+			if err := json.Unmarshal(config, &check.config); err != nil {
+				return nil, err
+			}
+		}
+		return check, nil
+	})
+}
+
+func (c *fixedCheck) Name() string {
+	return c.name
+}
+
+func (c *fixedCheck) Standalone() bool {
+	return c.config.Standalone
+}
+
+func (c *fixedCheck) Evaluate(ctx context.Context, params *CheckParameters) (Result, error) {
+	evaluated = append(evaluated, c.name)
+	if c.config.Error != "" {
+		return Result{}, errors.New(c.config.Error)
+	}
+	return Result{Name: c.name, Ready: c.config.Ready}, nil
+}
+
+func TestCompositeCheck(t *testing.T) {
+	for _, test := range []struct {
+		name      string
+		spec      string
+		ready     bool
+		reason    string
+		evaluated []string
+	}{
+		{
+			name:      "single",
+			spec:      `{"Type": "fixed", "Config": {"Ready": true}}`,
+			ready:     true,
+			evaluated: []string{"fixed"},
+		},
+		{
+			name: "all ready",
+			spec: `{"All": [
+				{"Name": "a", "Type": "fixed", "Config": {"Ready": true}},
+				{"Name": "b", "Type": "fixed", "Config": {"Ready": true}}
+			]}`,
+			ready:     true,
+			evaluated: []string{"a", "b"},
+		},
+		{
+			name: "all stops at the first not ready",
+			spec: `{"All": [
+				{"Name": "a", "Type": "fixed", "Config": {"Ready": true}},
+				{"Name": "b", "Type": "fixed"},
+				{"Name": "c", "Type": "fixed", "Config": {"Ready": true}}
+			]}`,
+			reason:    "not ready: b",
+			evaluated: []string{"a", "b"},
+		},
+		{
+			name: "any stops at the first ready",
+			spec: `{"Any": [
+				{"Name": "a", "Type": "fixed"},
+				{"Name": "b", "Type": "fixed", "Config": {"Ready": true}},
+				{"Name": "c", "Type": "fixed"}
+			]}`,
+			ready:     true,
+			evaluated: []string{"a", "b"},
+		},
+		{
+			name: "none ready",
+			spec: `{"Any": [
+				{"Name": "a", "Type": "fixed"},
+				{"Name": "b", "Type": "fixed", "Config": {"Error": "connection refused"}}
+			]}`,
+			reason:    "none ready: a, b",
+			evaluated: []string{"a", "b"},
+		},
+		{
+			name: "nested",
+			spec: `{"All": [
+				{"Name": "a", "Type": "fixed", "Config": {"Ready": true}},
+				{"Any": [
+					{"Name": "b", "Type": "fixed"},
+					{"Name": "c", "Type": "fixed", "Config": {"Ready": true}}
+				]}
+			]}`,
+			ready:     true,
+			evaluated: []string{"a", "b", "c"},
+		},
+	} {
+		spec, err := ParseCheckSpec(test.spec)
+		if !assert.NoError(t, err, test.name) {
+			continue
+		}
+		check, err := NewCheck(*spec)
+		if !assert.NoError(t, err, test.name) {
+			continue
+		}
+
+		evaluated = nil
+		result, err := check.Evaluate(context.Background(), &CheckParameters{})
+		assert.NoError(t, err, test.name)
+		assert.Equal(t, test.ready, result.Ready, test.name)
+		assert.Equal(t, test.reason, result.Reason, test.name)
+		assert.Equal(t, test.evaluated, evaluated, test.name)
+	}
+}
+
+func TestParseCheckSpecRejectsInvalidSpecs(t *testing.T) {
+	for _, test := range []struct {
+		name string
+		spec string
+	}{
+		{"empty", ``},
+		{"unknown field", `{"Type": "fixed", "Confg": {}}`},
+		{"unknown type", `{"Type": "no-such-check"}`},
+		{"no kind", `{"Name": "a"}`},
+		{"two kinds", `{"Type": "fixed", "All": [{"Type": "fixed"}]}`},
+		{"no members", `{"All": []}`},
+		{"duplicate names", `{"All": [{"Type": "fixed"}, {"Type": "fixed"}]}`},
+		{"standalone member", `{"Any": [{"Type": "fixed", "Config": {"Standalone": true}}]}`},
+		{"bad config", `{"Type": "fixed", "Config": {"Ready": "yes"}}`},
+	} {
+		_, err := ParseCheckSpec(test.spec)
+		assert.Error(t, err, test.name)
+	}
+}
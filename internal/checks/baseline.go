@@ -0,0 +1,87 @@
+package checks
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws/client"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/ssm"
+	"github.com/otterley/ec2-autoscaling-lifecycle-helpers/internal"
+	"github.com/pkg/errors"
+)
+
+func init() {
+	internal.RegisterCheck("ec2-baseline", newBaselineCheck)
+}
+
+// BaselineConfig configures the "ec2-baseline" check, which is ready once the
+// instance has passed its EC2 system and instance status checks and its SSM
+// agent is online.  Either half may be skipped.
+type BaselineConfig struct {
+	SkipStatusChecks bool `json:",omitempty"`
+	SkipSSMAgent     bool `json:",omitempty"`
+}
+
+type baselineCheck struct {
+	name   string
+	config BaselineConfig
+}
+
+func newBaselineCheck(name string, config json.RawMessage) (internal.Check, error) {
+	check := &baselineCheck{name: name}
+	if err := decodeConfig(config, &check.config); err != nil {
+		return nil, err
+	}
+	if check.config.SkipStatusChecks && check.config.SkipSSMAgent {
+		return nil, errors.New("SkipStatusChecks and SkipSSMAgent leave nothing to check")
+	}
+	return check, nil
+}
+
+func (c *baselineCheck) Name() string {
+	return c.name
+}
+
+func (c *baselineCheck) Evaluate(ctx context.Context, params *internal.CheckParameters) (internal.Result, error) {
+	sess := session.Must(session.NewSession())
+	result, err := CheckBaseline(ctx, sess, c.config, params.EC2InstanceID)
+	result.Name = c.name
+	return result, err
+}
+
+// CheckBaseline evaluates whether the EC2 instance has passed its status
+// checks and its SSM agent is online.
+func CheckBaseline(ctx context.Context, sess client.ConfigProvider, config BaselineConfig, ec2InstanceID string) (internal.Result, error) {
+	var result internal.Result
+
+	if !config.SkipStatusChecks {
+		failures, err := internal.InstanceStatusChecks(ctx, sess, ec2InstanceID)
+		if err != nil {
+			return result, errors.WithMessage(err, "InstanceStatusChecks")
+		}
+		result.Details = append(result.Details, failures...)
+	}
+
+	if !config.SkipSSMAgent {
+		status, err := internal.SSMAgentPingStatus(ctx, sess, ec2InstanceID)
+		if err != nil {
+			return result, errors.WithMessage(err, "SSMAgentPingStatus")
+		}
+		switch status {
+		case ssm.PingStatusOnline:
+		case "":
+			result.Details = append(result.Details, "SSM agent: not registered")
+		default:
+			result.Details = append(result.Details, "SSM agent: "+status)
+		}
+	}
+
+	if len(result.Details) > 0 {
+		result.Reason = strings.Join(result.Details, "; ")
+		return result, nil
+	}
+	result.Ready = true
+	return result, nil
+}
@@ -0,0 +1,21 @@
+// Package checks implements the readiness checks that can be named in an
+// internal.CheckSpec.  Importing it registers them.
+package checks
+
+import (
+	"bytes"
+	"encoding/json"
+
+	"github.com/pkg/errors"
+)
+
+// decodeConfig decodes a check's JSON configuration into v, rejecting unknown
+// fields.  An empty configuration leaves v alone.
+func decodeConfig(config json.RawMessage, v interface{}) error {
+	if len(bytes.TrimSpace(config)) == 0 {
+		return nil
+	}
+	dec := json.NewDecoder(bytes.NewBuffer(config))
+	dec.DisallowUnknownFields()
+	return errors.WithMessage(dec.Decode(v), "invalid config")
+}
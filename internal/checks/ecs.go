@@ -0,0 +1,190 @@
+package checks
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/client"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/ecs"
+	"github.com/otterley/ec2-autoscaling-lifecycle-helpers/internal"
+	"github.com/pkg/errors"
+)
+
+func init() {
+	internal.RegisterCheck("ecs-instance", newECSInstanceCheck)
+}
+
+// ECSInstanceConfig configures the "ecs-instance" check, which is ready once
+// the instance has joined one of the ECS clusters and meets the readiness
+// requirements.
+type ECSInstanceConfig struct {
+	// Clusters are searched for the instance; internal.AllClusters
+	// searches every cluster in the region.
+	Clusters             []string
+	RequiredTaskFamilies []string                `json:",omitempty"`
+	Readiness            *internal.ReadinessSpec `json:",omitempty"`
+	// Quarantine is an internal.Quarantine mode, if any.  A check that
+	// quarantines the instance can't be a member of an All or Any check.
+	Quarantine string `json:",omitempty"`
+}
+
+// ECSInstanceState is what the "ecs-instance" check learns about the instance
+// between iterations.
+type ECSInstanceState struct {
+	Cluster     string `json:",omitempty"`
+	InstanceID  string `json:",omitempty"`
+	Quarantined bool   `json:",omitempty"`
+}
+
+type ecsInstanceCheck struct {
+	name   string
+	config ECSInstanceConfig
+}
+
+func newECSInstanceCheck(name string, config json.RawMessage) (internal.Check, error) {
+	check := &ecsInstanceCheck{name: name}
+	if err := decodeConfig(config, &check.config); err != nil {
+		return nil, err
+	}
+	if len(check.config.Clusters) == 0 {
+		return nil, errors.New("Clusters is required")
+	}
+	if err := check.config.Readiness.Validate(); err != nil {
+		return nil, err
+	}
+	var err error
+	if check.config.Quarantine, err = internal.ParseQuarantine(check.config.Quarantine); err != nil {
+		return nil, err
+	}
+	if err := internal.ValidateQuarantine(check.config.Quarantine, check.config.RequiredTaskFamilies, check.config.Readiness); err != nil {
+		return nil, err
+	}
+	return check, nil
+}
+
+func (c *ecsInstanceCheck) Name() string {
+	return c.name
+}
+
+// Standalone is true if the instance is quarantined, since the quarantine is
+// only lifted once the check is ready.
+func (c *ecsInstanceCheck) Standalone() bool {
+	return c.config.Quarantine != ""
+}
+
+func (c *ecsInstanceCheck) Evaluate(ctx context.Context, params *internal.CheckParameters) (internal.Result, error) {
+	var state ECSInstanceState
+	if err := internal.LoadCheckState(params, c.name, &state); err != nil {
+		return internal.Result{}, err
+	}
+	sess := session.Must(session.NewSession())
+	result, err := CheckECSInstance(ctx, sess, c.config, params.EC2InstanceID, &state)
+	result.Name = c.name
+	if saveErr := internal.SaveCheckState(params, c.name, state); saveErr != nil {
+		return result, saveErr
+	}
+	return result, err
+}
+
+// CheckECSInstance evaluates whether the container instance on the EC2
+// instance is ready.  The instance is looked up, and quarantined if need be,
+// the first time round; state records what was done so that later calls carry
+// on from there.  Requirements that aren't met are listed in the result's
+// details.
+func CheckECSInstance(ctx context.Context, sess client.ConfigProvider, config ECSInstanceConfig, ec2InstanceID string, state *ECSInstanceState) (internal.Result, error) {
+	var result internal.Result
+
+	if state.InstanceID == "" {
+		clusters := config.Clusters
+		if state.Cluster != "" {
+			clusters = []string{state.Cluster}
+		}
+		cluster, id, err := internal.FindECSInstance(ctx, sess, clusters, ec2InstanceID)
+		if err != nil {
+			return result, errors.WithMessage(err, "FindECSInstance")
+		}
+		if id == "" {
+			// No instance ID assigned yet, so not ready
+			result.Reason = fmt.Sprintf("no ECS instance ID in clusters %v for EC2 instance ID %s", clusters, ec2InstanceID)
+			return result, nil
+		}
+		fmt.Printf("Found ECS instance %s in cluster %s\n", id, cluster)
+		state.Cluster, state.InstanceID = cluster, id
+	}
+
+	described, err := ecs.New(sess).DescribeContainerInstancesWithContext(
+		ctx,
+		&ecs.DescribeContainerInstancesInput{
+			Cluster:            aws.String(state.Cluster),
+			ContainerInstances: aws.StringSlice([]string{state.InstanceID}),
+		},
+	)
+	if err != nil {
+		return result, errors.WithMessage(err, "DescribeContainerInstances")
+	}
+	if len(described.ContainerInstances) != 1 {
+		return result, errors.New("assertion failure: container instances != 1")
+	}
+	instance := described.ContainerInstances[0]
+
+	// Quarantine the instance as soon as it has registered
+	if config.Quarantine != "" && !state.Quarantined {
+		if err := internal.Quarantine(ctx, sess, state.Cluster, state.InstanceID, config.Quarantine); err != nil {
+			return result, errors.WithMessage(err, "Quarantine")
+		}
+		state.Quarantined = true
+		result.Reason = fmt.Sprintf("ECS instance %s was just quarantined", state.InstanceID)
+		return result, nil
+	}
+
+	expectedStatus := "ACTIVE"
+	if state.Quarantined && config.Quarantine == internal.QuarantineDraining {
+		expectedStatus = "DRAINING"
+	}
+	if !aws.BoolValue(instance.AgentConnected) || aws.StringValue(instance.Status) != expectedStatus {
+		result.Reason = fmt.Sprintf("ECS instance %s not connected or state not %s", state.InstanceID, expectedStatus)
+		return result, nil
+	}
+
+	if config.Readiness != nil {
+		result.Details = config.Readiness.Host.Unmet(instance)
+		if len(result.Details) > 0 {
+			result.Reason = fmt.Sprintf("ECS instance %s fails host criteria", state.InstanceID)
+			return result, nil
+		}
+	}
+
+	reqs := config.Readiness.Requirements()
+	for _, family := range config.RequiredTaskFamilies {
+		reqs = append(reqs, internal.TaskRequirement{Family: family})
+	}
+	if len(reqs) > 0 {
+		var tasks []*ecs.Task
+		if err := internal.DescribeInstanceTasks(ctx, sess, state.Cluster, state.InstanceID,
+			func(page []*ecs.Task, failures []*ecs.Failure) bool {
+				tasks = append(tasks, page...)
+				return true
+			},
+		); err != nil {
+			return result, err
+		}
+		result.Details = internal.UnmetRequirements(reqs, tasks)
+		if len(result.Details) > 0 {
+			result.Reason = fmt.Sprintf("ECS instance %s lacks required tasks", state.InstanceID)
+			return result, nil
+		}
+	}
+
+	if state.Quarantined {
+		if err := internal.LiftQuarantine(ctx, sess, state.Cluster, state.InstanceID, config.Quarantine); err != nil {
+			return result, errors.WithMessage(err, "LiftQuarantine")
+		}
+		state.Quarantined = false
+	}
+
+	result.Ready = true
+	return result, nil
+}
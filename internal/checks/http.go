@@ -0,0 +1,291 @@
+package checks
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/otterley/ec2-autoscaling-lifecycle-helpers/internal"
+	"github.com/pkg/errors"
+)
+
+func init() {
+	internal.RegisterCheck("http", newHTTPCheck)
+}
+
+// maxRecordedBody is how much of a response body is kept for diagnostics.
+const maxRecordedBody = 512
+
+// HTTPConfig configures the "http" check, which is ready once every request
+// gets the expected response from the instance.
+type HTTPConfig struct {
+	Requests []HTTPRequest
+}
+
+// HTTPRequest is a request to be made to the instance's private IP address,
+// and the response it must get.
+type HTTPRequest struct {
+	// Scheme is "http" (the default) or "https".
+	Scheme string `json:",omitempty"`
+	// Port defaults to the scheme's.
+	Port int `json:",omitempty"`
+	// Path defaults to "/", and may include a query string.
+	Path    string            `json:",omitempty"`
+	Method  string            `json:",omitempty"`
+	Headers map[string]string `json:",omitempty"`
+	Body    string            `json:",omitempty"`
+	// Host overrides the Host header, and the TLS server name.
+	Host string `json:",omitempty"`
+	// Timeout is a Go duration string, defaulting to 5s.
+	Timeout string `json:",omitempty"`
+
+	// ExpectedStatus defaults to any 2xx status.
+	ExpectedStatus []int `json:",omitempty"`
+	// BodyContains must appear in the response body.
+	BodyContains string `json:",omitempty"`
+	// JSON lists values that must appear in a JSON response body.
+	JSON []JSONAssertion `json:",omitempty"`
+
+	// InsecureSkipVerify skips verification of the server's certificate.
+	InsecureSkipVerify bool `json:",omitempty"`
+	// CACertificate is a PEM-encoded CA certificate that the server's
+	// certificate is verified against, instead of the system roots.
+	CACertificate string `json:",omitempty"`
+}
+
+// JSONAssertion requires the value at Path in a JSON document to equal
+// Equals.  Path is a dotted list of object keys and array indexes, e.g.
+// "checks.0.status".
+type JSONAssertion struct {
+	Path   string
+	Equals interface{}
+}
+
+// HTTPResponse records the outcome of a request, for diagnostics.
+type HTTPResponse struct {
+	URL    string
+	Time   string
+	Status int    `json:",omitempty"`
+	Body   string `json:",omitempty"`
+	Error  string `json:",omitempty"`
+}
+
+// HTTPState records the last response to each request.
+type HTTPState struct {
+	LastResponses []HTTPResponse
+}
+
+type httpCheck struct {
+	name     string
+	config   HTTPConfig
+	timeouts []time.Duration
+	clients  []*http.Client
+}
+
+func newHTTPCheck(name string, config json.RawMessage) (internal.Check, error) {
+	check := &httpCheck{name: name}
+	if err := decodeConfig(config, &check.config); err != nil {
+		return nil, err
+	}
+	if len(check.config.Requests) == 0 {
+		return nil, errors.New("Requests is required")
+	}
+	for i, req := range check.config.Requests {
+		timeout := 5 * time.Second
+		if req.Timeout != "" {
+			var err error
+			if timeout, err = time.ParseDuration(req.Timeout); err != nil {
+				return nil, errors.WithMessage(err, fmt.Sprintf("request %d: Timeout", i))
+			}
+		}
+		switch req.Scheme {
+		case "", "http", "https":
+		default:
+			return nil, fmt.Errorf("request %d: unknown scheme %q", i, req.Scheme)
+		}
+		tlsConfig := &tls.Config{
+			ServerName:         req.Host,
+			InsecureSkipVerify: req.InsecureSkipVerify,
+		}
+		if req.CACertificate != "" {
+			tlsConfig.RootCAs = x509.NewCertPool()
+			if !tlsConfig.RootCAs.AppendCertsFromPEM([]byte(req.CACertificate)) {
+				return nil, fmt.Errorf("request %d: no certificates in CACertificate", i)
+			}
+		}
+		check.timeouts = append(check.timeouts, timeout)
+		check.clients = append(check.clients, &http.Client{
+			// Each iteration runs in a fresh invocation, so there's no
+			// use keeping connections open
+			Transport: &http.Transport{TLSClientConfig: tlsConfig, DisableKeepAlives: true},
+			// Redirects may lead away from the instance
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		})
+	}
+	return check, nil
+}
+
+func (c *httpCheck) Name() string {
+	return c.name
+}
+
+func (c *httpCheck) Evaluate(ctx context.Context, params *internal.CheckParameters) (internal.Result, error) {
+	result := internal.Result{Name: c.name, Ready: true}
+	var state HTTPState
+	for i, req := range c.config.Requests {
+		response, problem := c.do(ctx, i, params.InternalIPAddr)
+		if internal.OutOfTime(ctx) {
+			return result, ctx.Err()
+		}
+		state.LastResponses = append(state.LastResponses, response)
+		if problem != "" {
+			result.Ready = false
+			result.Details = append(result.Details, fmt.Sprintf("%s %s: %s", method(req), response.URL, problem))
+		}
+	}
+	if !result.Ready {
+		result.Reason = "unexpected responses"
+	}
+	return result, internal.SaveCheckState(params, c.name, state)
+}
+
+// do makes the i'th request and returns a record of the response, along with
+// what was wrong with it, if anything.
+func (c *httpCheck) do(ctx context.Context, i int, addr string) (HTTPResponse, string) {
+	req := c.config.Requests[i]
+	scheme := req.Scheme
+	if scheme == "" {
+		scheme = "http"
+	}
+	host := addr
+	if req.Port != 0 {
+		host = net.JoinHostPort(addr, strconv.Itoa(req.Port))
+	}
+	path := req.Path
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+	target := scheme + "://" + host + path
+	record := HTTPResponse{URL: target, Time: time.Now().Format(time.RFC3339)}
+
+	if _, err := url.Parse(target); err != nil {
+		record.Error = err.Error()
+		return record, record.Error
+	}
+	var body io.Reader
+	if req.Body != "" {
+		body = strings.NewReader(req.Body)
+	}
+	reqCtx, cancel := context.WithTimeout(ctx, c.timeouts[i])
+	defer cancel()
+	httpReq, err := http.NewRequest(method(req), target, body)
+	if err != nil {
+		record.Error = err.Error()
+		return record, record.Error
+	}
+	httpReq = httpReq.WithContext(reqCtx)
+	for key, value := range req.Headers {
+		httpReq.Header.Set(key, value)
+	}
+	if req.Host != "" {
+		httpReq.Host = req.Host
+	}
+
+	resp, err := c.clients[i].Do(httpReq)
+	if err != nil {
+		record.Error = err.Error()
+		return record, record.Error
+	}
+	defer resp.Body.Close()
+	content, err := ioutil.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	record.Status = resp.StatusCode
+	record.Body = string(content)
+	if len(record.Body) > maxRecordedBody {
+		record.Body = record.Body[:maxRecordedBody]
+	}
+	if err != nil {
+		record.Error = err.Error()
+		return record, record.Error
+	}
+
+	if !expectedStatus(req.ExpectedStatus, resp.StatusCode) {
+		return record, fmt.Sprintf("status %d", resp.StatusCode)
+	}
+	if req.BodyContains != "" && !strings.Contains(string(content), req.BodyContains) {
+		return record, fmt.Sprintf("body doesn't contain %q", req.BodyContains)
+	}
+	if len(req.JSON) > 0 {
+		var doc interface{}
+		if err := json.Unmarshal(content, &doc); err != nil {
+			return record, "body isn't JSON: " + err.Error()
+		}
+		for _, assertion := range req.JSON {
+			value, ok := jsonPath(doc, assertion.Path)
+			if !ok {
+				return record, fmt.Sprintf("no value at %s", assertion.Path)
+			}
+			if !reflect.DeepEqual(value, assertion.Equals) {
+				return record, fmt.Sprintf("%s is %v, not %v", assertion.Path, value, assertion.Equals)
+			}
+		}
+	}
+	return record, ""
+}
+
+func method(req HTTPRequest) string {
+	if req.Method == "" {
+		return http.MethodGet
+	}
+	return strings.ToUpper(req.Method)
+}
+
+func expectedStatus(expected []int, status int) bool {
+	if len(expected) == 0 {
+		return status >= 200 && status < 300
+	}
+	for _, s := range expected {
+		if s == status {
+			return true
+		}
+	}
+	return false
+}
+
+// jsonPath returns the value at a dotted path in a decoded JSON document.
+func jsonPath(doc interface{}, path string) (interface{}, bool) {
+	if path == "" {
+		return doc, true
+	}
+	for _, key := range strings.Split(path, ".") {
+		switch node := doc.(type) {
+		case map[string]interface{}:
+			value, ok := node[key]
+			if !ok {
+				return nil, false
+			}
+			doc = value
+		case []interface{}:
+			i, err := strconv.Atoi(key)
+			if err != nil || i < 0 || i >= len(node) {
+				return nil, false
+			}
+			doc = node[i]
+		default:
+			return nil, false
+		}
+	}
+	return doc, true
+}
@@ -0,0 +1,129 @@
+package checks
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/Shopify/sarama"
+	"github.com/otterley/ec2-autoscaling-lifecycle-helpers/internal"
+	"github.com/pkg/errors"
+)
+
+func init() {
+	internal.RegisterCheck("kafka", newKafkaCheck)
+}
+
+// KafkaConfig configures the "kafka" check, which is ready once every
+// partition known to the broker on the instance has all its replicas in sync.
+type KafkaConfig struct {
+	// Port defaults to 9092.
+	Port int `json:",omitempty"`
+}
+
+type kafkaCheck struct {
+	name   string
+	config KafkaConfig
+}
+
+func newKafkaCheck(name string, config json.RawMessage) (internal.Check, error) {
+	check := &kafkaCheck{name: name, config: KafkaConfig{Port: 9092}}
+	if err := decodeConfig(config, &check.config); err != nil {
+		return nil, err
+	}
+	if check.config.Port < 1 || check.config.Port > 65535 {
+		return nil, errors.New("Port must be between 1 and 65535")
+	}
+	return check, nil
+}
+
+func (c *kafkaCheck) Name() string {
+	return c.name
+}
+
+func (c *kafkaCheck) Evaluate(ctx context.Context, params *internal.CheckParameters) (internal.Result, error) {
+	result, err := CheckKafka(ctx, params.InternalIPAddr, c.config.Port)
+	result.Name = c.name
+	return result, err
+}
+
+// CheckKafka evaluates whether every partition known to the broker at
+// addr:port has all its replicas in sync.  Any error talking to the broker is
+// taken to be retriable and reported as the reason it isn't ready; an error is
+// returned only if ctx ran out before every partition was checked.
+func CheckKafka(ctx context.Context, addr string, port int) (internal.Result, error) {
+	var result internal.Result
+
+	client, err := newKafkaClient(ctx, addr, port)
+	if err != nil {
+		result.Reason = err.Error()
+		return result, nil
+	}
+	defer client.Close()
+
+	topics, err := client.Topics()
+	if err != nil {
+		result.Reason = err.Error()
+		return result, nil
+	}
+	for _, topic := range topics {
+		partitions, err := client.Partitions(topic)
+		if err != nil {
+			result.Reason = err.Error()
+			return result, nil
+		}
+		for _, partition := range partitions {
+			if internal.OutOfTime(ctx) {
+				return result, ctx.Err()
+			}
+			replicas, err := client.Replicas(topic, partition)
+			if err != nil {
+				result.Reason = err.Error()
+				return result, nil
+			}
+			isrs, err := client.InSyncReplicas(topic, partition)
+			if err != nil {
+				result.Reason = err.Error()
+				return result, nil
+			}
+			fmt.Printf("Topic %s[%d]: %d replicas, %d ISRs\n", topic, partition, len(replicas), len(isrs))
+			if len(replicas) != len(isrs) {
+				result.Reason = fmt.Sprintf("topic %s[%d] not in sync", topic, partition)
+				return result, nil
+			}
+		}
+	}
+
+	result.Ready = true
+	return result, nil
+}
+
+// newKafkaClient returns a Kafka client for the broker at addr:port.  The
+// Kafka client doesn't accept a context, so its network timeouts are bounded
+// by the time remaining before ctx's deadline instead.
+func newKafkaClient(ctx context.Context, addr string, port int) (sarama.Client, error) {
+	config := sarama.NewConfig()
+	if deadline, ok := ctx.Deadline(); ok {
+		remaining := time.Until(deadline)
+		if remaining <= 0 {
+			return nil, ctx.Err()
+		}
+		if remaining < config.Net.DialTimeout {
+			config.Net.DialTimeout = remaining
+		}
+		if remaining < config.Net.ReadTimeout {
+			config.Net.ReadTimeout = remaining
+		}
+		if remaining < config.Net.WriteTimeout {
+			config.Net.WriteTimeout = remaining
+		}
+		config.Metadata.Retry.Max = 0
+	}
+	return sarama.NewClient(
+		[]string{net.JoinHostPort(addr, strconv.Itoa(port))},
+		config,
+	)
+}
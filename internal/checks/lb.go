@@ -0,0 +1,95 @@
+package checks
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/otterley/ec2-autoscaling-lifecycle-helpers/internal"
+	"github.com/pkg/errors"
+)
+
+func init() {
+	internal.RegisterCheck("lb-target-health", newLBTargetHealthCheck)
+}
+
+// LBTargetHealthConfig configures the "lb-target-health" check, which is
+// ready once the instance is healthy in every target group and InService
+// with every classic load balancer.
+type LBTargetHealthConfig struct {
+	TargetGroupARNs   []string `json:",omitempty"`
+	LoadBalancerNames []string `json:",omitempty"`
+	// Discover adds the target groups and classic load balancers attached
+	// to the Auto Scaling Group.  Auto Scaling only registers the instance
+	// with them once it goes InService, after its launch lifecycle action
+	// is complete, so Discover suits checks run after launch, not those
+	// holding up the launch lifecycle action.
+	Discover bool `json:",omitempty"`
+}
+
+type lbTargetHealthCheck struct {
+	name   string
+	config LBTargetHealthConfig
+}
+
+func newLBTargetHealthCheck(name string, config json.RawMessage) (internal.Check, error) {
+	check := &lbTargetHealthCheck{name: name}
+	if err := decodeConfig(config, &check.config); err != nil {
+		return nil, err
+	}
+	if !check.config.Discover && len(check.config.TargetGroupARNs) == 0 && len(check.config.LoadBalancerNames) == 0 {
+		return nil, errors.New("TargetGroupARNs, LoadBalancerNames or Discover is required")
+	}
+	return check, nil
+}
+
+func (c *lbTargetHealthCheck) Name() string {
+	return c.name
+}
+
+func (c *lbTargetHealthCheck) Evaluate(ctx context.Context, params *internal.CheckParameters) (internal.Result, error) {
+	result := internal.Result{Name: c.name}
+	sess := session.Must(session.NewSession())
+
+	targetGroups := c.config.TargetGroupARNs
+	loadBalancers := c.config.LoadBalancerNames
+	if c.config.Discover {
+		discoveredGroups, discoveredBalancers, err := internal.AutoScalingGroupLoadBalancers(ctx, sess, params.AutoScalingGroupName)
+		if err != nil {
+			return result, errors.WithMessage(err, "AutoScalingGroupLoadBalancers")
+		}
+		targetGroups = internal.AppendMissing(targetGroups, discoveredGroups)
+		loadBalancers = internal.AppendMissing(loadBalancers, discoveredBalancers)
+	}
+	if len(targetGroups) == 0 && len(loadBalancers) == 0 {
+		result.Ready = true
+		result.Reason = "no load balancers to check"
+		return result, nil
+	}
+
+	for _, arn := range targetGroups {
+		unhealthy, err := internal.InstanceTargetHealth(ctx, sess, arn, params.EC2InstanceID)
+		if err != nil {
+			return result, err
+		}
+		result.Details = append(result.Details, unhealthy...)
+	}
+	for _, name := range loadBalancers {
+		unhealthy, err := internal.InstanceLoadBalancerHealth(ctx, sess, name, params.EC2InstanceID)
+		if err != nil {
+			return result, err
+		}
+		if unhealthy != "" {
+			result.Details = append(result.Details, unhealthy)
+		}
+	}
+
+	if len(result.Details) > 0 {
+		result.Reason = fmt.Sprintf("instance not healthy in %d of %d registrations checked",
+			len(result.Details), len(targetGroups)+len(loadBalancers))
+		return result, nil
+	}
+	result.Ready = true
+	return result, nil
+}
@@ -0,0 +1,156 @@
+package checks
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/otterley/ec2-autoscaling-lifecycle-helpers/internal"
+	"github.com/pkg/errors"
+)
+
+func init() {
+	internal.RegisterCheck("port", newPortCheck)
+}
+
+// PortConfig configures the "port" check, which is ready once every port on
+// the instance's private IP address accepts connections.
+type PortConfig struct {
+	Ports []PortSpec
+	// Timeout is a Go duration string bounding each connection attempt,
+	// defaulting to 3s.
+	Timeout string `json:",omitempty"`
+}
+
+// PortSpec is a TCP port that must accept connections.
+type PortSpec struct {
+	Port int
+	// TLS requires a TLS handshake to complete with a certificate that's
+	// valid for ServerName, which is then required.
+	TLS        bool   `json:",omitempty"`
+	ServerName string `json:",omitempty"`
+	// CACertificate is a PEM-encoded CA certificate that the server's
+	// certificate is verified against, instead of the system roots.
+	CACertificate string `json:",omitempty"`
+}
+
+type portCheck struct {
+	name       string
+	config     PortConfig
+	timeout    time.Duration
+	tlsConfigs []*tls.Config
+}
+
+func newPortCheck(name string, config json.RawMessage) (internal.Check, error) {
+	check := &portCheck{name: name, timeout: 3 * time.Second}
+	if err := decodeConfig(config, &check.config); err != nil {
+		return nil, err
+	}
+	if len(check.config.Ports) == 0 {
+		return nil, errors.New("Ports is required")
+	}
+	if check.config.Timeout != "" {
+		var err error
+		if check.timeout, err = time.ParseDuration(check.config.Timeout); err != nil {
+			return nil, errors.WithMessage(err, "Timeout")
+		}
+	}
+	for _, port := range check.config.Ports {
+		if port.Port < 1 || port.Port > 65535 {
+			return nil, fmt.Errorf("port %d must be between 1 and 65535", port.Port)
+		}
+		if !port.TLS {
+			check.tlsConfigs = append(check.tlsConfigs, nil)
+			continue
+		}
+		if port.ServerName == "" {
+			return nil, fmt.Errorf("port %d: ServerName is required for TLS", port.Port)
+		}
+		tlsConfig := &tls.Config{ServerName: port.ServerName}
+		if port.CACertificate != "" {
+			tlsConfig.RootCAs = x509.NewCertPool()
+			if !tlsConfig.RootCAs.AppendCertsFromPEM([]byte(port.CACertificate)) {
+				return nil, fmt.Errorf("port %d: no certificates in CACertificate", port.Port)
+			}
+		}
+		check.tlsConfigs = append(check.tlsConfigs, tlsConfig)
+	}
+	return check, nil
+}
+
+func (c *portCheck) Name() string {
+	return c.name
+}
+
+// Evaluate dials every port at once, and reports the status of each in the
+// result's details.
+func (c *portCheck) Evaluate(ctx context.Context, params *internal.CheckParameters) (internal.Result, error) {
+	statuses := make([]string, len(c.config.Ports))
+	failed := make([]bool, len(c.config.Ports))
+	var wg sync.WaitGroup
+	for i := range c.config.Ports {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			port := c.config.Ports[i]
+			if err := c.dial(ctx, params.InternalIPAddr, i); err != nil {
+				statuses[i] = fmt.Sprintf("tcp/%d: %v", port.Port, err)
+				failed[i] = true
+				return
+			}
+			if port.TLS {
+				statuses[i] = fmt.Sprintf("tcp/%d: ok, TLS certificate valid for %s", port.Port, port.ServerName)
+			} else {
+				statuses[i] = fmt.Sprintf("tcp/%d: ok", port.Port)
+			}
+		}(i)
+	}
+	wg.Wait()
+	if internal.OutOfTime(ctx) {
+		return internal.Result{Name: c.name}, ctx.Err()
+	}
+
+	result := internal.Result{Name: c.name, Ready: true, Details: statuses}
+	var down []string
+	for i, port := range c.config.Ports {
+		if failed[i] {
+			result.Ready = false
+			down = append(down, strconv.Itoa(port.Port))
+		}
+	}
+	if !result.Ready {
+		result.Reason = fmt.Sprintf("ports not ready: %v", down)
+	}
+	return result, nil
+}
+
+// dial connects to the i'th port and, if need be, completes a TLS handshake.
+func (c *portCheck) dial(ctx context.Context, addr string, i int) error {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", net.JoinHostPort(addr, strconv.Itoa(c.config.Ports[i].Port)))
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if c.tlsConfigs[i] == nil {
+		return nil
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	tlsConn := tls.Client(conn, c.tlsConfigs[i])
+	if err := tlsConn.Handshake(); err != nil {
+		return errors.WithMessage(err, "TLS handshake")
+	}
+	return nil
+}
@@ -0,0 +1,73 @@
+package checks
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/otterley/ec2-autoscaling-lifecycle-helpers/internal"
+)
+
+func init() {
+	internal.RegisterCheck("ssm-command", newSSMCommandCheck)
+}
+
+// The "ssm-command" check is configured with an internal.CommandSpec.  It's
+// ready once the command has exited with code 0 on the instance; a command
+// that fails is sent again on the next iteration.
+type ssmCommandCheck struct {
+	name   string
+	config internal.CommandSpec
+}
+
+func newSSMCommandCheck(name string, config json.RawMessage) (internal.Check, error) {
+	check := &ssmCommandCheck{name: name}
+	if err := decodeConfig(config, &check.config); err != nil {
+		return nil, err
+	}
+	if err := check.config.Validate(); err != nil {
+		return nil, err
+	}
+	return check, nil
+}
+
+func (c *ssmCommandCheck) Name() string {
+	return c.name
+}
+
+func (c *ssmCommandCheck) Evaluate(ctx context.Context, params *internal.CheckParameters) (internal.Result, error) {
+	result := internal.Result{Name: c.name}
+	sess := session.Must(session.NewSession())
+
+	var run internal.CommandRun
+	if err := internal.LoadCheckState(params, c.name, &run); err != nil {
+		return result, err
+	}
+
+	if run.CommandID == "" || (run.Done && !run.Succeeded) {
+		id, err := internal.SendCommand(ctx, sess, c.config, params.EC2InstanceID)
+		if err != nil {
+			return result, err
+		}
+		run = internal.CommandRun{CommandID: id}
+		result.Reason = "sent command " + id
+		return result, internal.SaveCheckState(params, c.name, run)
+	}
+
+	if !run.Done {
+		if err := internal.PollCommand(ctx, sess, c.config, params.EC2InstanceID, &run); err != nil {
+			return result, err
+		}
+	}
+	if run.Stdout != "" {
+		result.Details = append(result.Details, "stdout: "+run.Stdout)
+	}
+	if run.Stderr != "" {
+		result.Details = append(result.Details, "stderr: "+run.Stderr)
+	}
+	result.Ready = run.Succeeded
+	if !result.Ready {
+		result.Reason = run.String()
+	}
+	return result, internal.SaveCheckState(params, c.name, run)
+}
@@ -0,0 +1,46 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+)
+
+// DefaultSafetyMargin is the amount of time reserved before the Lambda
+// function's deadline so that a handler can stop what it's doing and return a
+// partial result, rather than being killed mid-operation.
+const DefaultSafetyMargin = 5 * time.Second
+
+// WithSafetyMargin returns a copy of ctx whose deadline is moved up by the
+// safety margin.  The margin defaults to DefaultSafetyMargin and can be
+// overridden by setting the SAFETY_MARGIN environment variable to a Go
+// duration string.  The margin never exceeds a quarter of the time remaining,
+// so that short-lived functions still get useful work done.  If ctx has no
+// deadline, neither will the returned context.
+func WithSafetyMargin(ctx context.Context) (context.Context, context.CancelFunc) {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		return context.WithCancel(ctx)
+	}
+	margin := DefaultSafetyMargin
+	if val := os.Getenv("SAFETY_MARGIN"); val != "" {
+		d, err := time.ParseDuration(val)
+		if err != nil {
+			fmt.Printf("Ignoring invalid SAFETY_MARGIN %q: %v\n", val, err)
+		} else {
+			margin = d
+		}
+	}
+	if remaining := time.Until(deadline); margin > remaining/4 {
+		margin = remaining / 4
+	}
+	return context.WithDeadline(ctx, deadline.Add(-margin))
+}
+
+// OutOfTime returns true if ctx was cancelled or its deadline has passed.
+// Handlers use it to tell a failed AWS request apart from one that was
+// interrupted by the safety margin.
+func OutOfTime(ctx context.Context) bool {
+	return ctx.Err() != nil
+}
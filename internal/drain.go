@@ -0,0 +1,378 @@
+package internal
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/client"
+	"github.com/aws/aws-sdk-go/service/ecs"
+	"github.com/pkg/errors"
+)
+
+// DefaultStopConcurrency is the default maximum number of StopTask requests
+// that may be in flight at once.
+const DefaultStopConcurrency = 10
+
+// DrainStage is one step of a drain plan.  When the stage begins, the drainer
+// waits for Delay, stops the tasks chosen by Selector, and then waits until
+// they have all exited or Timeout has elapsed before moving on to the next
+// stage.  Delay and Timeout are Go duration strings; an empty Timeout waits
+// until the lifecycle action's deadline.
+type DrainStage struct {
+	Name     string
+	Selector TaskSelector
+	Delay    string `json:",omitempty"`
+	Timeout  string `json:",omitempty"`
+}
+
+// DrainStageStatus records the progress of a drain stage.  Times are formatted
+// as RFC 3339 strings, and are empty until the corresponding event happens.
+type DrainStageStatus struct {
+	Name           string
+	StartedAt      string `json:",omitempty"`
+	TasksStoppedAt string `json:",omitempty"`
+	CompletedAt    string `json:",omitempty"`
+	TimedOut       bool
+	RemainingTasks int
+	TaskStops      TaskStopReport
+}
+
+// ParseDrainPlan parses a JSON-encoded list of drain stages.  It returns nil if
+// s is empty.
+func ParseDrainPlan(s string) ([]DrainStage, error) {
+	if strings.TrimSpace(s) == "" {
+		return nil, nil
+	}
+	var plan []DrainStage
+	dec := json.NewDecoder(bytes.NewBufferString(s))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&plan); err != nil {
+		return nil, errors.WithMessage(err, "invalid drain plan")
+	}
+	for i, stage := range plan {
+		if stage.Name == "" {
+			plan[i].Name = fmt.Sprintf("stage-%d", i+1)
+		}
+		if err := stage.Selector.Validate(); err != nil {
+			return nil, errors.WithMessage(err, plan[i].Name)
+		}
+		for _, d := range []string{stage.Delay, stage.Timeout} {
+			if d == "" {
+				continue
+			}
+			if _, err := time.ParseDuration(d); err != nil {
+				return nil, errors.WithMessage(err, plan[i].Name)
+			}
+		}
+	}
+	return plan, nil
+}
+
+// CountMatchingTasks returns the number of tasks on the ECS instance that are
+// chosen by the selector.
+func CountMatchingTasks(ctx context.Context, sess client.ConfigProvider, cluster, ecsInstanceID string, selector *TaskSelector) (int, error) {
+	count := 0
+	err := DescribeInstanceTasks(ctx, sess, cluster, ecsInstanceID,
+		func(tasks []*ecs.Task, failures []*ecs.Failure) bool {
+			for _, task := range tasks {
+				if selector.Matches(task) {
+					count++
+				}
+			}
+			return true
+		},
+	)
+	return count, err
+}
+
+// DrainIfCapacityAvailable drains the ECS instance, unless the capacity check
+// is enabled and the rest of the cluster can't absorb the instance's service
+// tasks.  In that case the shortfall is recorded in params and, if enabled,
+// the Auto Scaling Group is scaled out by one instance.  If services are to be
+// over-provisioned, it instead waits for their extra tasks to start.  The
+// caller can tell the outcome by params.Draining.
+func DrainIfCapacityAvailable(ctx context.Context, sess client.ConfigProvider, params *DrainParameters) error {
+	if params.OverProvision {
+		// The extra tasks take the drained tasks' places, so there's no
+		// need to check capacity as well.
+		ready, err := OverProvisionServices(ctx, sess, params)
+		if err != nil {
+			return errors.WithMessage(err, "OverProvisionServices")
+		}
+		if !ready {
+			fmt.Printf("Waiting for extra service tasks to start before draining ECS instance %s\n", params.ECSInstanceID)
+			return nil
+		}
+	} else if params.CheckCapacity {
+		shortfall, err := CapacityShortfall(ctx, sess, params.ECSCluster, params.ECSInstanceID)
+		if err != nil {
+			return errors.WithMessage(err, "CapacityShortfall")
+		}
+		params.CapacityShortfall = shortfall
+		if len(shortfall) > 0 {
+			fmt.Printf("Cluster %s lacks capacity for %d tasks on ECS instance %s; not draining yet\n",
+				params.ECSCluster, len(shortfall), params.ECSInstanceID)
+			for _, reason := range shortfall {
+				fmt.Printf("No room for %s\n", reason)
+			}
+			if params.ScaleOutForCapacity && !params.ScaledOut {
+				params.ScaledOut, err = IncrementDesiredCapacity(ctx, sess, params.AutoScalingGroupName)
+				if err != nil {
+					return errors.WithMessage(err, "IncrementDesiredCapacity")
+				}
+			}
+			return nil
+		}
+	}
+	return DrainECSInstance(ctx, sess, params)
+}
+
+// DrainECSInstance sets the drained ECS instance to DRAINING and then stops
+// the tasks chosen by its stop selector, recording the outcome in params.  If
+// the drain is to wait for service replacements, the services with tasks on
+// the instance are recorded first, since they start leaving once it's
+// DRAINING.  Once every step has succeeded, the instance is marked as drained
+// for the lifecycle action and Draining is set; it's safe to call again after
+// an error.
+func DrainECSInstance(ctx context.Context, sess client.ConfigProvider, params *DrainParameters) error {
+	if params.WaitForServiceReplacements && params.DrainedServices == nil {
+		services, err := InstanceServices(ctx, sess, params.ECSCluster, params.ECSInstanceID)
+		if err != nil {
+			return errors.WithMessage(err, "InstanceServices")
+		}
+		if err := recordDrainedServices(ctx, sess, params, services); err != nil {
+			return err
+		}
+	}
+
+	fmt.Printf("Setting ECS instance %s on cluster %s to DRAINING state\n", params.ECSInstanceID, params.ECSCluster)
+	if _, err := ecs.New(sess).UpdateContainerInstancesStateWithContext(
+		ctx,
+		&ecs.UpdateContainerInstancesStateInput{
+			Cluster:            aws.String(params.ECSCluster),
+			ContainerInstances: aws.StringSlice([]string{params.ECSInstanceID}),
+			Status:             aws.String("DRAINING"),
+		},
+	); err != nil {
+		return errors.WithMessage(err, "UpdateContainerInstancesState")
+	}
+
+	if err := stopSelectedTasks(ctx, sess, params); err != nil {
+		return err
+	}
+
+	// Let a retried start know that there's nothing left to do
+	if params.LifecycleActionToken != "" {
+		if err := SetDrainMarker(ctx, sess, params.ECSCluster, params.ECSInstanceID, params.LifecycleActionToken); err != nil {
+			return errors.WithMessage(err, "SetDrainMarker")
+		}
+	}
+
+	params.Draining = true
+	return nil
+}
+
+// ResumeDrain restores the drain state of an ECS instance that was already
+// drained for the lifecycle action, without draining it again.  The services
+// that had tasks on the instance are found among its stopped tasks as well as
+// its running ones; services whose tasks stopped shortly before the drain are
+// included too, which only means waiting for them to settle.  Tasks chosen by
+// the stop selector that are still running are checked for scale-in
+// protection.
+func ResumeDrain(ctx context.Context, sess client.ConfigProvider, params *DrainParameters) error {
+	if params.WaitForServiceReplacements && params.DrainedServices == nil {
+		services, err := DrainedInstanceServices(ctx, sess, params.ECSCluster, params.ECSInstanceID)
+		if err != nil {
+			return errors.WithMessage(err, "DrainedInstanceServices")
+		}
+		if err := recordDrainedServices(ctx, sess, params, services); err != nil {
+			return err
+		}
+	}
+
+	if params.StopSelector != nil {
+		var remaining []*ecs.Task
+		if err := DescribeInstanceTasks(ctx, sess, params.ECSCluster, params.ECSInstanceID,
+			func(tasks []*ecs.Task, failures []*ecs.Failure) bool {
+				for _, task := range tasks {
+					if params.StopSelector.Matches(task) {
+						remaining = append(remaining, task)
+					}
+				}
+				return true
+			},
+		); err != nil {
+			return errors.WithMessage(err, "DescribeInstanceTasks")
+		}
+		protected, err := ProtectedTasks(ctx, sess, params.ECSCluster, taskARNs(remaining))
+		if err != nil {
+			return errors.WithMessage(err, "ProtectedTasks")
+		}
+		params.ProtectedTasksPending = len(protected) > 0
+	}
+
+	params.Draining = true
+	return nil
+}
+
+// recordDrainedServices records the services that the drain is to wait for,
+// leaving out DAEMON services: they don't replace tasks elsewhere, but their
+// desired count simply drops when the instance goes away.
+func recordDrainedServices(ctx context.Context, sess client.ConfigProvider, params *DrainParameters, services []string) error {
+	daemons, err := DaemonServices(ctx, sess, params.ECSCluster, services)
+	if err != nil {
+		return errors.WithMessage(err, "DaemonServices")
+	}
+	params.DrainedServices = []string{}
+	for _, service := range services {
+		if !daemons[service] {
+			params.DrainedServices = append(params.DrainedServices, service)
+		}
+	}
+	fmt.Printf("Services with tasks on ECS instance %s: %v\n", params.ECSInstanceID, params.DrainedServices)
+	return nil
+}
+
+// RetryProtectedTasks tries again to stop the tasks chosen by the stop
+// selector that were kept from stopping by scale-in protection.  Tasks already
+// stopped aren't listed again, so only those still running are retried.
+func RetryProtectedTasks(ctx context.Context, sess client.ConfigProvider, params *DrainParameters) error {
+	if !params.ProtectedTasksPending {
+		return nil
+	}
+	return stopSelectedTasks(ctx, sess, params)
+}
+
+// stopSelectedTasks stops the tasks chosen by the stop selector, if any, and
+// records the outcome in params.
+func stopSelectedTasks(ctx context.Context, sess client.ConfigProvider, params *DrainParameters) error {
+	if params.StopSelector == nil {
+		return nil
+	}
+	fmt.Printf("Stopping selected tasks on ECS instance %s in cluster %s\n", params.ECSInstanceID, params.ECSCluster)
+	concurrency := params.StopConcurrency
+	if concurrency < 1 {
+		concurrency = DefaultStopConcurrency
+	}
+	report, err := StopMatchingTasks(ctx, sess, params.ECSCluster, params.ECSInstanceID, concurrency, params.StopSelector)
+	params.TaskStops.Merge(report)
+	fmt.Printf("Task stops on ECS instance %s: %s\n", params.ECSInstanceID, report)
+	for _, protected := range report.Protected {
+		fmt.Printf("Not stopping task %s: %s\n", protected.TaskARN, protected.Reason)
+	}
+	for _, failure := range report.Failed {
+		fmt.Printf("Failed to stop task %s: %s\n", failure.TaskARN, failure.Reason)
+	}
+	if err != nil {
+		return errors.WithMessage(err, "StopMatchingTasks")
+	}
+	params.ProtectedTasksPending = len(report.Protected) > 0
+	return nil
+}
+
+// StopMatchingTasks stops every task on the ECS instance that is chosen by the
+// selector, with at most concurrency StopTask calls in flight at once.  The
+// tasks are all listed before any are stopped, so that stopping them doesn't
+// disturb pagination.  Tasks with scale-in protection aren't stopped, but
+// recorded as protected in the report.  An error is returned only if the tasks
+// could not be listed or described; individual tasks that could not be
+// stopped are recorded in the report.
+func StopMatchingTasks(ctx context.Context, sess client.ConfigProvider, cluster, ecsInstanceID string, concurrency int, selector *TaskSelector) (TaskStopReport, error) {
+	var (
+		report  TaskStopReport
+		matched []*ecs.Task
+	)
+	if err := DescribeInstanceTasks(ctx, sess, cluster, ecsInstanceID,
+		func(tasks []*ecs.Task, failures []*ecs.Failure) bool {
+			for _, failure := range failures {
+				report.Skipped = append(report.Skipped, TaskOutcome{
+					TaskARN: aws.StringValue(failure.Arn),
+					Reason:  "DescribeTasks: " + aws.StringValue(failure.Reason),
+				})
+			}
+			for _, task := range tasks {
+				if selector.Matches(task) {
+					matched = append(matched, task)
+				}
+			}
+			return true
+		},
+	); err != nil {
+		return report, err
+	}
+
+	report.Matched = len(matched)
+	protected, err := ProtectedTasks(ctx, sess, cluster, taskARNs(matched))
+	if err != nil {
+		return report, err
+	}
+	var stoppable []*ecs.Task
+	for _, task := range matched {
+		arn := aws.StringValue(task.TaskArn)
+		if expires, ok := protected[arn]; ok {
+			report.Protected = append(report.Protected, TaskOutcome{
+				TaskARN: arn,
+				Reason:  "scale-in protected until " + expires.Format(time.RFC3339),
+			})
+			continue
+		}
+		stoppable = append(stoppable, task)
+	}
+
+	stopTasks(ctx, ecs.New(sess), cluster, stoppable, concurrency, &report)
+	if OutOfTime(ctx) {
+		return report, ctx.Err()
+	}
+	return report, nil
+}
+
+// stopTasks stops a batch of tasks concurrently and records the outcome of
+// each in report.  Once ctx is done, no more tasks are attempted; those left
+// aren't recorded, so that a later attempt can stop them.
+func stopTasks(ctx context.Context, client *ecs.ECS, cluster string, tasks []*ecs.Task, concurrency int, report *TaskStopReport) {
+	var (
+		mu  sync.Mutex
+		wg  sync.WaitGroup
+		sem = make(chan struct{}, concurrency)
+	)
+loop:
+	for _, task := range tasks {
+		if ctx.Err() != nil {
+			break
+		}
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+			break loop
+		}
+		wg.Add(1)
+		go func(taskARN string) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			_, err := client.StopTaskWithContext(
+				ctx,
+				&ecs.StopTaskInput{
+					Cluster: aws.String(cluster),
+					Task:    aws.String(taskARN),
+					Reason:  aws.String("ECS instance drainer requested stop"),
+				},
+			)
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				report.Failed = append(report.Failed, TaskOutcome{TaskARN: taskARN, Reason: err.Error()})
+				return
+			}
+			report.Stopped = append(report.Stopped, taskARN)
+		}(aws.StringValue(task.TaskArn))
+	}
+	wg.Wait()
+}
@@ -0,0 +1,59 @@
+package internal
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/ecs"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseDrainPlan(t *testing.T) {
+	plan, err := ParseDrainPlan(" ")
+	assert.NoError(t, err)
+	assert.Nil(t, plan)
+
+	plan, err = ParseDrainPlan(`[
+		{"Name": "batch", "Selector": {"Include": [{"Group": "family:batch-*"}]}, "Timeout": "10m"},
+		{"Selector": {"Include": [{"Group": "service:*"}]}, "Delay": "30s"}
+	]`)
+	assert.NoError(t, err)
+	assert.Equal(t, []DrainStage{
+		{Name: "batch", Selector: TaskSelector{Include: []TaskRule{{Group: "family:batch-*"}}}, Timeout: "10m"},
+		{Name: "stage-2", Selector: TaskSelector{Include: []TaskRule{{Group: "service:*"}}}, Delay: "30s"},
+	}, plan)
+}
+
+func TestParseDrainPlanRejectsInvalidPlans(t *testing.T) {
+	for _, test := range []struct {
+		name string
+		plan string
+	}{
+		{"not a list", `{"Name": "batch"}`},
+		{"unknown field", `[{"Name": "batch", "Timout": "10m"}]`},
+		{"unknown selector field", `[{"Selector": {"Include": [{"Grup": "family:batch"}]}}]`},
+		{"bad pattern", `[{"Selector": {"Include": [{"Group": "family:[batch"}]}}]`},
+		{"bad delay", `[{"Delay": "30"}]`},
+		{"bad timeout", `[{"Timeout": "ten minutes"}]`},
+	} {
+		_, err := ParseDrainPlan(test.plan)
+		assert.Error(t, err, test.name)
+	}
+}
+
+func TestStopTasksLeavesUnattemptedTasksOutOfReport(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	client := ecs.New(session.Must(session.NewSession()), aws.NewConfig().WithRegion("us-east-1"))
+	tasks := []*ecs.Task{
+		{TaskArn: aws.String("arn:aws:ecs:us-east-1:123456789012:task/cluster/1")},
+		{TaskArn: aws.String("arn:aws:ecs:us-east-1:123456789012:task/cluster/2")},
+	}
+
+	var report TaskStopReport
+	stopTasks(ctx, client, "cluster", tasks, 1, &report)
+	assert.Empty(t, report.Stopped)
+	assert.Empty(t, report.Failed)
+}
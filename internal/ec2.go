@@ -0,0 +1,62 @@
+package internal
+
+import (
+	"context"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/client"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/pkg/errors"
+)
+
+// InstancePrivateIP returns the private IP address of the EC2 instance.
+func InstancePrivateIP(ctx context.Context, sess client.ConfigProvider, ec2InstanceID string) (string, error) {
+	ec2Client := ec2.New(sess)
+	result, err := ec2Client.DescribeInstancesWithContext(
+		ctx,
+		&ec2.DescribeInstancesInput{
+			InstanceIds: aws.StringSlice([]string{ec2InstanceID}),
+		},
+	)
+	if err != nil {
+		return "", errors.WithMessage(err, "DescribeInstances")
+	}
+	if len(result.Reservations) != 1 {
+		return "", errors.New("assertion failure: reservation count != 1")
+	}
+	if len(result.Reservations[0].Instances) != 1 {
+		return "", errors.New("assertion failure: instance count != 1")
+	}
+	return aws.StringValue(result.Reservations[0].Instances[0].PrivateIpAddress), nil
+}
+
+// InstanceStatusChecks returns a description of each way in which the EC2
+// instance isn't running with its system and instance status checks passed.
+func InstanceStatusChecks(ctx context.Context, sess client.ConfigProvider, ec2InstanceID string) ([]string, error) {
+	result, err := ec2.New(sess).DescribeInstanceStatusWithContext(
+		ctx,
+		&ec2.DescribeInstanceStatusInput{
+			InstanceIds:         aws.StringSlice([]string{ec2InstanceID}),
+			IncludeAllInstances: aws.Bool(true),
+		},
+	)
+	if err != nil {
+		return nil, errors.WithMessage(err, "DescribeInstanceStatus")
+	}
+	if len(result.InstanceStatuses) != 1 {
+		return nil, errors.New("assertion failure: instance status count != 1")
+	}
+	status := result.InstanceStatuses[0]
+
+	var failures []string
+	if state := aws.StringValue(status.InstanceState.Name); state != ec2.InstanceStateNameRunning {
+		failures = append(failures, "instance state: "+state)
+	}
+	if check := aws.StringValue(status.SystemStatus.Status); check != ec2.SummaryStatusOk {
+		failures = append(failures, "system status: "+check)
+	}
+	if check := aws.StringValue(status.InstanceStatus.Status); check != ec2.SummaryStatusOk {
+		failures = append(failures, "instance status: "+check)
+	}
+	return failures, nil
+}
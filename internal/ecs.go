@@ -1,27 +1,77 @@
 package internal
 
 import (
+	"context"
+	"fmt"
+	"strings"
+
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/client"
 	"github.com/aws/aws-sdk-go/service/ecs"
 	"github.com/pkg/errors"
 )
 
+// AllClusters, given as a cluster to FindECSInstance, searches every ECS
+// cluster in the region.
+const AllClusters = "*"
+
 var ecsInstanceARNCache map[string]string
 
-func GetECSInstanceARN(sess client.ConfigProvider, cluster, ec2InstanceID string) (string, error) {
+// FindECSInstance searches the given clusters in turn for the container
+// instance running on the EC2 instance, and returns the cluster it belongs to
+// along with its ARN.  Empty strings are returned if it isn't found.
+func FindECSInstance(ctx context.Context, sess client.ConfigProvider, clusters []string, ec2InstanceID string) (string, string, error) {
+	for _, cluster := range clusters {
+		if cluster == AllClusters {
+			var err error
+			if clusters, err = listClusters(ctx, sess); err != nil {
+				return "", "", err
+			}
+			break
+		}
+	}
+	for _, cluster := range clusters {
+		arn, err := GetECSInstanceARN(ctx, sess, cluster, ec2InstanceID)
+		if err != nil {
+			return "", "", errors.WithMessage(err, fmt.Sprintf("cluster %s", cluster))
+		}
+		if arn != "" {
+			return cluster, arn, nil
+		}
+	}
+	return "", "", nil
+}
+
+func listClusters(ctx context.Context, sess client.ConfigProvider) ([]string, error) {
+	var clusters []string
+	if err := ecs.New(sess).ListClustersPagesWithContext(
+		ctx,
+		&ecs.ListClustersInput{},
+		func(page *ecs.ListClustersOutput, lastPage bool) bool {
+			clusters = append(clusters, aws.StringValueSlice(page.ClusterArns)...)
+			return !lastPage
+		},
+	); err != nil {
+		return nil, errors.WithMessage(err, "ListClusters")
+	}
+	return clusters, nil
+}
+
+func GetECSInstanceARN(ctx context.Context, sess client.ConfigProvider, cluster, ec2InstanceID string) (string, error) {
 	var (
 		arn      string
 		innerErr error
 	)
 
 	// Return a cached response if possible
-	if arn, ok := ecsInstanceARNCache[ec2InstanceID]; ok {
+	cacheKey := cluster + "/" + ec2InstanceID
+	if arn, ok := ecsInstanceARNCache[cacheKey]; ok {
 		return arn, nil
 	}
 
 	client := ecs.New(sess)
-	if err := client.ListContainerInstancesPages(
+	if err := client.ListContainerInstancesPagesWithContext(
+		ctx,
 		&ecs.ListContainerInstancesInput{
 			Cluster: aws.String(cluster),
 		},
@@ -30,7 +80,8 @@ func GetECSInstanceARN(sess client.ConfigProvider, cluster, ec2InstanceID string
 				return false // nothing to do
 			}
 			var instances *ecs.DescribeContainerInstancesOutput
-			instances, innerErr = client.DescribeContainerInstances(
+			instances, innerErr = client.DescribeContainerInstancesWithContext(
+				ctx,
 				&ecs.DescribeContainerInstancesInput{
 					Cluster:            aws.String(cluster),
 					ContainerInstances: page.ContainerInstanceArns,
@@ -52,7 +103,417 @@ func GetECSInstanceARN(sess client.ConfigProvider, cluster, ec2InstanceID string
 	}
 	if arn != "" {
 		// Write to cache
-		ecsInstanceARNCache[ec2InstanceID] = arn
+		ecsInstanceARNCache[cacheKey] = arn
 	}
 	return arn, errors.WithMessage(innerErr, "DescribeContainerInstances")
 }
+
+// DrainMarkerAttribute is the container instance attribute in which the
+// drainer records the lifecycle action token it drained the instance for.
+const DrainMarkerAttribute = "ecs-instance-drainer.lifecycle-action-token"
+
+// DrainMarker returns the status of the container instance and the value of
+// its DrainMarkerAttribute, which is empty if it isn't set.
+func DrainMarker(ctx context.Context, sess client.ConfigProvider, cluster, ecsInstanceID string) (string, string, error) {
+	result, err := ecs.New(sess).DescribeContainerInstancesWithContext(
+		ctx,
+		&ecs.DescribeContainerInstancesInput{
+			Cluster:            aws.String(cluster),
+			ContainerInstances: aws.StringSlice([]string{ecsInstanceID}),
+		},
+	)
+	if err != nil {
+		return "", "", errors.WithMessage(err, "DescribeContainerInstances")
+	}
+	if len(result.ContainerInstances) != 1 {
+		return "", "", errors.New("assertion failure: container instances != 1")
+	}
+	instance := result.ContainerInstances[0]
+	for _, attribute := range instance.Attributes {
+		if aws.StringValue(attribute.Name) == DrainMarkerAttribute {
+			return aws.StringValue(instance.Status), aws.StringValue(attribute.Value), nil
+		}
+	}
+	return aws.StringValue(instance.Status), "", nil
+}
+
+// SetDrainMarker sets the container instance's DrainMarkerAttribute to the
+// lifecycle action token.
+func SetDrainMarker(ctx context.Context, sess client.ConfigProvider, cluster, ecsInstanceID, token string) error {
+	return PutInstanceAttribute(ctx, sess, cluster, ecsInstanceID, DrainMarkerAttribute, token)
+}
+
+// ClearDrainMarker removes the container instance's DrainMarkerAttribute.
+func ClearDrainMarker(ctx context.Context, sess client.ConfigProvider, cluster, ecsInstanceID string) error {
+	return DeleteInstanceAttribute(ctx, sess, cluster, ecsInstanceID, DrainMarkerAttribute)
+}
+
+// PutInstanceAttribute sets a custom attribute on the container instance.
+func PutInstanceAttribute(ctx context.Context, sess client.ConfigProvider, cluster, ecsInstanceID, name, value string) error {
+	_, err := ecs.New(sess).PutAttributesWithContext(
+		ctx,
+		&ecs.PutAttributesInput{
+			Cluster: aws.String(cluster),
+			Attributes: []*ecs.Attribute{
+				{
+					Name:       aws.String(name),
+					Value:      aws.String(value),
+					TargetType: aws.String("container-instance"),
+					TargetId:   aws.String(ecsInstanceID),
+				},
+			},
+		},
+	)
+	return errors.WithMessage(err, "PutAttributes")
+}
+
+// DeleteInstanceAttribute removes a custom attribute from the container
+// instance.
+func DeleteInstanceAttribute(ctx context.Context, sess client.ConfigProvider, cluster, ecsInstanceID, name string) error {
+	_, err := ecs.New(sess).DeleteAttributesWithContext(
+		ctx,
+		&ecs.DeleteAttributesInput{
+			Cluster: aws.String(cluster),
+			Attributes: []*ecs.Attribute{
+				{
+					Name:       aws.String(name),
+					TargetType: aws.String("container-instance"),
+					TargetId:   aws.String(ecsInstanceID),
+				},
+			},
+		},
+	)
+	return errors.WithMessage(err, "DeleteAttributes")
+}
+
+// SetContainerInstanceStatus sets the container instance to ACTIVE or
+// DRAINING.
+func SetContainerInstanceStatus(ctx context.Context, sess client.ConfigProvider, cluster, ecsInstanceID, status string) error {
+	_, err := ecs.New(sess).UpdateContainerInstancesStateWithContext(
+		ctx,
+		&ecs.UpdateContainerInstancesStateInput{
+			Cluster:            aws.String(cluster),
+			ContainerInstances: aws.StringSlice([]string{ecsInstanceID}),
+			Status:             aws.String(status),
+		},
+	)
+	return errors.WithMessage(err, "UpdateContainerInstancesState")
+}
+
+// DescribeInstanceTasks lists the tasks on an ECS container instance whose
+// desired status is RUNNING, and calls fn with each page of task descriptions,
+// including tags.  Iteration stops early if fn returns false.
+func DescribeInstanceTasks(ctx context.Context, sess client.ConfigProvider, cluster, ecsInstanceID string, fn func(tasks []*ecs.Task, failures []*ecs.Failure) bool) error {
+	return describeInstanceTasks(ctx, sess, cluster, ecsInstanceID, "RUNNING", fn)
+}
+
+func describeInstanceTasks(ctx context.Context, sess client.ConfigProvider, cluster, ecsInstanceID, desiredStatus string, fn func(tasks []*ecs.Task, failures []*ecs.Failure) bool) error {
+	var innerErr error
+
+	client := ecs.New(sess)
+	if err := client.ListTasksPagesWithContext(
+		ctx,
+		&ecs.ListTasksInput{
+			Cluster:           aws.String(cluster),
+			ContainerInstance: aws.String(ecsInstanceID),
+			DesiredStatus:     aws.String(desiredStatus),
+		},
+		func(page *ecs.ListTasksOutput, lastPage bool) bool {
+			if len(page.TaskArns) == 0 {
+				return false // nothing to do
+			}
+			var tasks *ecs.DescribeTasksOutput
+			tasks, innerErr = client.DescribeTasksWithContext(
+				ctx,
+				&ecs.DescribeTasksInput{
+					Cluster: aws.String(cluster),
+					Tasks:   page.TaskArns,
+					Include: aws.StringSlice([]string{"TAGS"}),
+				},
+			)
+			if innerErr != nil {
+				return false
+			}
+			return fn(tasks.Tasks, tasks.Failures) && !lastPage
+		},
+	); err != nil {
+		return errors.WithMessage(err, "ListTasks")
+	}
+	return errors.WithMessage(innerErr, "DescribeTasks")
+}
+
+// DescribeServices describes the named services in the cluster, in batches
+// of as many as DescribeServices accepts at once.
+func DescribeServices(ctx context.Context, sess client.ConfigProvider, cluster string, serviceNames []string) ([]*ecs.Service, []*ecs.Failure, error) {
+	var (
+		services []*ecs.Service
+		failures []*ecs.Failure
+	)
+	client := ecs.New(sess)
+	for i := 0; i < len(serviceNames); i += 10 {
+		j := i + 10
+		if j > len(serviceNames) {
+			j = len(serviceNames)
+		}
+		result, err := client.DescribeServicesWithContext(
+			ctx,
+			&ecs.DescribeServicesInput{
+				Cluster:  aws.String(cluster),
+				Services: aws.StringSlice(serviceNames[i:j]),
+			},
+		)
+		if err != nil {
+			return services, failures, errors.WithMessage(err, "DescribeServices")
+		}
+		services = append(services, result.Services...)
+		failures = append(failures, result.Failures...)
+	}
+	return services, failures, nil
+}
+
+// DaemonServices returns the subset of the named services in the cluster that
+// use the DAEMON scheduling strategy.
+func DaemonServices(ctx context.Context, sess client.ConfigProvider, cluster string, serviceNames []string) (map[string]bool, error) {
+	daemons := make(map[string]bool)
+	services, _, err := DescribeServices(ctx, sess, cluster, serviceNames)
+	if err != nil {
+		return daemons, err
+	}
+	for _, service := range services {
+		if aws.StringValue(service.SchedulingStrategy) == "DAEMON" {
+			daemons[aws.StringValue(service.ServiceName)] = true
+		}
+	}
+	return daemons, nil
+}
+
+// TaskServiceName returns the name of the service that started the task, or
+// an empty string if the task doesn't belong to a service.
+func TaskServiceName(task *ecs.Task) string {
+	group := aws.StringValue(task.Group)
+	if !strings.HasPrefix(group, "service:") {
+		return ""
+	}
+	return strings.TrimPrefix(group, "service:")
+}
+
+// InstanceServices returns the names of the services that have tasks running
+// on the ECS container instance.
+func InstanceServices(ctx context.Context, sess client.ConfigProvider, cluster, ecsInstanceID string) ([]string, error) {
+	return instanceServices(ctx, sess, cluster, ecsInstanceID, "RUNNING")
+}
+
+// DrainedInstanceServices returns the names of the services with tasks on the
+// ECS container instance, whether they're still running or have stopped.  ECS
+// keeps listing stopped tasks for at least an hour, so this finds the services
+// whose tasks have already left a draining instance.
+func DrainedInstanceServices(ctx context.Context, sess client.ConfigProvider, cluster, ecsInstanceID string) ([]string, error) {
+	return instanceServices(ctx, sess, cluster, ecsInstanceID, "RUNNING", "STOPPED")
+}
+
+func instanceServices(ctx context.Context, sess client.ConfigProvider, cluster, ecsInstanceID string, desiredStatuses ...string) ([]string, error) {
+	var services []string
+	seen := make(map[string]bool)
+	for _, desiredStatus := range desiredStatuses {
+		if err := describeInstanceTasks(ctx, sess, cluster, ecsInstanceID, desiredStatus,
+			func(tasks []*ecs.Task, failures []*ecs.Failure) bool {
+				for _, task := range tasks {
+					if name := TaskServiceName(task); name != "" && !seen[name] {
+						seen[name] = true
+						services = append(services, name)
+					}
+				}
+				return true
+			},
+		); err != nil {
+			return services, err
+		}
+	}
+	return services, nil
+}
+
+// UnsettledServices checks whether each of the named services has replaced
+// the tasks it lost: its running count has reached its desired count, its
+// latest deployment is the only one and is fully running, and its running
+// tasks are healthy targets in every target group it's attached to.  It returns
+// a description of each service that hasn't settled yet.
+func UnsettledServices(ctx context.Context, sess client.ConfigProvider, cluster string, serviceNames []string) ([]string, error) {
+	var unsettled []string
+	services, failures, err := DescribeServices(ctx, sess, cluster, serviceNames)
+	if err != nil {
+		return unsettled, err
+	}
+	for _, service := range services {
+		name := aws.StringValue(service.ServiceName)
+		if aws.StringValue(service.Status) != "ACTIVE" {
+			// Deleted services have nothing to replace
+			continue
+		}
+		running, desired := aws.Int64Value(service.RunningCount), aws.Int64Value(service.DesiredCount)
+		if running < desired {
+			unsettled = append(unsettled, fmt.Sprintf("%s: %d of %d tasks running", name, running, desired))
+			continue
+		}
+		if len(service.Deployments) != 1 {
+			unsettled = append(unsettled, fmt.Sprintf("%s: %d deployments in progress", name, len(service.Deployments)))
+			continue
+		}
+		if deployment := service.Deployments[0]; aws.Int64Value(deployment.RunningCount) < aws.Int64Value(deployment.DesiredCount) {
+			unsettled = append(unsettled, fmt.Sprintf("%s: deployment %s has %d of %d tasks running",
+				name, aws.StringValue(deployment.Id), aws.Int64Value(deployment.RunningCount), aws.Int64Value(deployment.DesiredCount)))
+			continue
+		}
+		// Classic load balancers register instances rather than tasks,
+		// so only target groups can tell us about replacement tasks.
+		var targets map[string]map[string]bool
+		for _, lb := range service.LoadBalancers {
+			if aws.StringValue(lb.TargetGroupArn) == "" {
+				continue
+			}
+			if targets == nil {
+				if targets, err = ServiceTargets(ctx, sess, cluster, service); err != nil {
+					return unsettled, err
+				}
+			}
+			reason, err := TargetGroupUnhealthy(ctx, sess, aws.StringValue(lb.TargetGroupArn), targets[aws.StringValue(lb.TargetGroupArn)])
+			if err != nil {
+				return unsettled, err
+			}
+			if reason != "" {
+				unsettled = append(unsettled, fmt.Sprintf("%s: %s", name, reason))
+				break
+			}
+		}
+	}
+	for _, failure := range failures {
+		if aws.StringValue(failure.Reason) != "MISSING" {
+			unsettled = append(unsettled, fmt.Sprintf("%s: %s", aws.StringValue(failure.Arn), aws.StringValue(failure.Reason)))
+		}
+	}
+	return unsettled, nil
+}
+
+// ServiceTargets returns the load balancer targets of the service's running
+// tasks in each of its target groups, keyed by target group ARN.  Each target
+// is given as "id:port": the task's IP address and container port if it has
+// its own network interface, or its EC2 instance ID and host port otherwise.
+func ServiceTargets(ctx context.Context, sess client.ConfigProvider, cluster string, service *ecs.Service) (map[string]map[string]bool, error) {
+	var (
+		tasks    []*ecs.Task
+		innerErr error
+	)
+	client := ecs.New(sess)
+	if err := client.ListTasksPagesWithContext(
+		ctx,
+		&ecs.ListTasksInput{
+			Cluster:       aws.String(cluster),
+			ServiceName:   service.ServiceName,
+			DesiredStatus: aws.String("RUNNING"),
+		},
+		func(page *ecs.ListTasksOutput, lastPage bool) bool {
+			if len(page.TaskArns) == 0 {
+				return false // nothing to do
+			}
+			var result *ecs.DescribeTasksOutput
+			result, innerErr = client.DescribeTasksWithContext(
+				ctx,
+				&ecs.DescribeTasksInput{
+					Cluster: aws.String(cluster),
+					Tasks:   page.TaskArns,
+				},
+			)
+			if innerErr != nil {
+				return false
+			}
+			tasks = append(tasks, result.Tasks...)
+			return !lastPage
+		},
+	); err != nil {
+		return nil, errors.WithMessage(err, "ListTasks")
+	}
+	if innerErr != nil {
+		return nil, errors.WithMessage(innerErr, "DescribeTasks")
+	}
+
+	instanceIDs, err := taskInstanceIDs(ctx, client, cluster, tasks)
+	if err != nil {
+		return nil, err
+	}
+
+	targets := make(map[string]map[string]bool)
+	for _, lb := range service.LoadBalancers {
+		arn := aws.StringValue(lb.TargetGroupArn)
+		if arn == "" {
+			continue
+		}
+		if targets[arn] == nil {
+			targets[arn] = make(map[string]bool)
+		}
+		for _, task := range tasks {
+			for _, target := range taskTargets(task, lb, instanceIDs) {
+				targets[arn][target] = true
+			}
+		}
+	}
+	return targets, nil
+}
+
+// taskTargets returns the targets that the task registers for the load
+// balancer, as "id:port" strings.
+func taskTargets(task *ecs.Task, lb *ecs.LoadBalancer, instanceIDs map[string]string) []string {
+	var targets []string
+	for _, container := range task.Containers {
+		if aws.StringValue(container.Name) != aws.StringValue(lb.ContainerName) {
+			continue
+		}
+		for _, eni := range container.NetworkInterfaces {
+			if ip := aws.StringValue(eni.PrivateIpv4Address); ip != "" {
+				targets = append(targets, fmt.Sprintf("%s:%d", ip, aws.Int64Value(lb.ContainerPort)))
+			}
+		}
+		if len(container.NetworkInterfaces) > 0 {
+			continue
+		}
+		instanceID := instanceIDs[aws.StringValue(task.ContainerInstanceArn)]
+		for _, binding := range container.NetworkBindings {
+			if aws.Int64Value(binding.ContainerPort) == aws.Int64Value(lb.ContainerPort) {
+				targets = append(targets, fmt.Sprintf("%s:%d", instanceID, aws.Int64Value(binding.HostPort)))
+			}
+		}
+	}
+	return targets
+}
+
+// taskInstanceIDs returns the EC2 instance ID of each container instance that
+// the tasks run on, keyed by container instance ARN.
+func taskInstanceIDs(ctx context.Context, client *ecs.ECS, cluster string, tasks []*ecs.Task) (map[string]string, error) {
+	var arns []string
+	instanceIDs := make(map[string]string)
+	for _, task := range tasks {
+		arn := aws.StringValue(task.ContainerInstanceArn)
+		if _, ok := instanceIDs[arn]; arn != "" && !ok {
+			instanceIDs[arn] = ""
+			arns = append(arns, arn)
+		}
+	}
+	for i := 0; i < len(arns); i += 100 {
+		j := i + 100
+		if j > len(arns) {
+			j = len(arns)
+		}
+		result, err := client.DescribeContainerInstancesWithContext(
+			ctx,
+			&ecs.DescribeContainerInstancesInput{
+				Cluster:            aws.String(cluster),
+				ContainerInstances: aws.StringSlice(arns[i:j]),
+			},
+		)
+		if err != nil {
+			return nil, errors.WithMessage(err, "DescribeContainerInstances")
+		}
+		for _, instance := range result.ContainerInstances {
+			instanceIDs[aws.StringValue(instance.ContainerInstanceArn)] = aws.StringValue(instance.Ec2InstanceId)
+		}
+	}
+	return instanceIDs, nil
+}
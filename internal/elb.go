@@ -0,0 +1,214 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/client"
+	"github.com/aws/aws-sdk-go/service/elb"
+	"github.com/aws/aws-sdk-go/service/elbv2"
+	"github.com/pkg/errors"
+)
+
+// TargetGroupUnhealthy returns a description of why the given targets in the
+// target group aren't healthy, or an empty string if they are.  The targets
+// are given as "id:port", as returned by ServiceTargets, and other targets in
+// the target group are ignored, as are the given targets while they're
+// draining or unused.  The targets are healthy if at least one of them is
+// healthy and none is still in its initial health checks or failing them.
+// Targets whose health is unavailable, e.g. because health checks are
+// disabled, count as healthy.  No targets at all are healthy too.
+func TargetGroupUnhealthy(ctx context.Context, sess client.ConfigProvider, targetGroupARN string, targets map[string]bool) (string, error) {
+	if len(targets) == 0 {
+		return "", nil
+	}
+	result, err := elbv2.New(sess).DescribeTargetHealthWithContext(
+		ctx,
+		&elbv2.DescribeTargetHealthInput{
+			TargetGroupArn: aws.String(targetGroupARN),
+		},
+	)
+	if err != nil {
+		return "", errors.WithMessage(err, "DescribeTargetHealth")
+	}
+	healthy := 0
+	for _, target := range result.TargetHealthDescriptions {
+		id := fmt.Sprintf("%s:%d", aws.StringValue(target.Target.Id), aws.Int64Value(target.Target.Port))
+		if !targets[id] {
+			continue
+		}
+		switch state := aws.StringValue(target.TargetHealth.State); state {
+		case elbv2.TargetHealthStateEnumHealthy, elbv2.TargetHealthStateEnumUnavailable:
+			healthy++
+		case elbv2.TargetHealthStateEnumInitial, elbv2.TargetHealthStateEnumUnhealthy:
+			return fmt.Sprintf("target %s in %s is %s", id, targetGroupARN, state), nil
+		}
+	}
+	if healthy == 0 {
+		return fmt.Sprintf("no healthy targets in %s", targetGroupARN), nil
+	}
+	return "", nil
+}
+
+// InstanceTargetHealth returns a description of each registration of the EC2
+// instance in the target group that isn't healthy.  If the instance isn't
+// registered at all, that's described instead.
+func InstanceTargetHealth(ctx context.Context, sess client.ConfigProvider, targetGroupARN, ec2InstanceID string) ([]string, error) {
+	// Listing every target finds the instance whichever ports it's
+	// registered on.
+	result, err := elbv2.New(sess).DescribeTargetHealthWithContext(
+		ctx,
+		&elbv2.DescribeTargetHealthInput{
+			TargetGroupArn: aws.String(targetGroupARN),
+		},
+	)
+	if err != nil {
+		return nil, errors.WithMessage(err, "DescribeTargetHealth")
+	}
+	registered := false
+	var unhealthy []string
+	for _, description := range result.TargetHealthDescriptions {
+		if aws.StringValue(description.Target.Id) != ec2InstanceID {
+			continue
+		}
+		registered = true
+		health := description.TargetHealth
+		if state := aws.StringValue(health.State); state != elbv2.TargetHealthStateEnumHealthy {
+			unhealthy = append(unhealthy, fmt.Sprintf("target group %s port %d: %s (%s)",
+				targetGroupARN, aws.Int64Value(description.Target.Port), state, aws.StringValue(health.Description)))
+		}
+	}
+	if !registered {
+		unhealthy = append(unhealthy, fmt.Sprintf("target group %s: instance not registered", targetGroupARN))
+	}
+	return unhealthy, nil
+}
+
+// InstanceLoadBalancerHealth returns a description of why the EC2 instance
+// isn't InService with the classic load balancer, or an empty string if it is.
+// Every instance is listed, since naming one that isn't registered is an
+// error.
+func InstanceLoadBalancerHealth(ctx context.Context, sess client.ConfigProvider, loadBalancerName, ec2InstanceID string) (string, error) {
+	result, err := elb.New(sess).DescribeInstanceHealthWithContext(
+		ctx,
+		&elb.DescribeInstanceHealthInput{
+			LoadBalancerName: aws.String(loadBalancerName),
+		},
+	)
+	if err != nil {
+		return "", errors.WithMessage(err, "DescribeInstanceHealth")
+	}
+	for _, state := range result.InstanceStates {
+		if aws.StringValue(state.InstanceId) != ec2InstanceID {
+			continue
+		}
+		if aws.StringValue(state.State) == "InService" {
+			return "", nil
+		}
+		return fmt.Sprintf("load balancer %s: %s (%s)", loadBalancerName, aws.StringValue(state.State), aws.StringValue(state.Description)), nil
+	}
+	return fmt.Sprintf("load balancer %s: instance not registered", loadBalancerName), nil
+}
+
+// DeregisterInstanceTargets deregisters the EC2 instance from the target
+// group on every port it's registered on.  It returns false if the instance
+// wasn't registered.
+func DeregisterInstanceTargets(ctx context.Context, sess client.ConfigProvider, targetGroupARN, ec2InstanceID string) (bool, error) {
+	client := elbv2.New(sess)
+	result, err := client.DescribeTargetHealthWithContext(
+		ctx,
+		&elbv2.DescribeTargetHealthInput{
+			TargetGroupArn: aws.String(targetGroupARN),
+		},
+	)
+	if err != nil {
+		return false, errors.WithMessage(err, "DescribeTargetHealth")
+	}
+	var targets []*elbv2.TargetDescription
+	for _, description := range result.TargetHealthDescriptions {
+		if aws.StringValue(description.Target.Id) != ec2InstanceID {
+			continue
+		}
+		// Targets already on their way out needn't be deregistered again
+		if aws.StringValue(description.TargetHealth.State) == elbv2.TargetHealthStateEnumDraining {
+			continue
+		}
+		targets = append(targets, description.Target)
+	}
+	if len(targets) == 0 {
+		return false, nil
+	}
+	_, err = client.DeregisterTargetsWithContext(
+		ctx,
+		&elbv2.DeregisterTargetsInput{
+			TargetGroupArn: aws.String(targetGroupARN),
+			Targets:        targets,
+		},
+	)
+	if err != nil {
+		return false, errors.WithMessage(err, "DeregisterTargets")
+	}
+	return true, nil
+}
+
+// DeregisterInstanceFromLoadBalancer deregisters the EC2 instance from the
+// classic load balancer.
+func DeregisterInstanceFromLoadBalancer(ctx context.Context, sess client.ConfigProvider, loadBalancerName, ec2InstanceID string) error {
+	_, err := elb.New(sess).DeregisterInstancesFromLoadBalancerWithContext(
+		ctx,
+		&elb.DeregisterInstancesFromLoadBalancerInput{
+			LoadBalancerName: aws.String(loadBalancerName),
+			Instances:        []*elb.Instance{{InstanceId: aws.String(ec2InstanceID)}},
+		},
+	)
+	return errors.WithMessage(err, "DeregisterInstancesFromLoadBalancer")
+}
+
+// InstanceTargetsDraining returns a description of each registration of the
+// EC2 instance in the target group that hasn't finished deregistering.
+func InstanceTargetsDraining(ctx context.Context, sess client.ConfigProvider, targetGroupARN, ec2InstanceID string) ([]string, error) {
+	result, err := elbv2.New(sess).DescribeTargetHealthWithContext(
+		ctx,
+		&elbv2.DescribeTargetHealthInput{
+			TargetGroupArn: aws.String(targetGroupARN),
+		},
+	)
+	if err != nil {
+		return nil, errors.WithMessage(err, "DescribeTargetHealth")
+	}
+	var pending []string
+	for _, description := range result.TargetHealthDescriptions {
+		if aws.StringValue(description.Target.Id) != ec2InstanceID {
+			continue
+		}
+		if state := aws.StringValue(description.TargetHealth.State); state != elbv2.TargetHealthStateEnumUnused {
+			pending = append(pending, fmt.Sprintf("target group %s port %d: %s",
+				targetGroupARN, aws.Int64Value(description.Target.Port), state))
+		}
+	}
+	return pending, nil
+}
+
+// InstanceLoadBalancerDraining returns a description of why the EC2 instance
+// hasn't finished deregistering from the classic load balancer, or an empty
+// string if it has.
+func InstanceLoadBalancerDraining(ctx context.Context, sess client.ConfigProvider, loadBalancerName, ec2InstanceID string) (string, error) {
+	// Asking for an instance that's no longer registered is an error, so
+	// list them all instead.
+	result, err := elb.New(sess).DescribeInstanceHealthWithContext(
+		ctx,
+		&elb.DescribeInstanceHealthInput{
+			LoadBalancerName: aws.String(loadBalancerName),
+		},
+	)
+	if err != nil {
+		return "", errors.WithMessage(err, "DescribeInstanceHealth")
+	}
+	for _, state := range result.InstanceStates {
+		if aws.StringValue(state.InstanceId) == ec2InstanceID {
+			return fmt.Sprintf("load balancer %s: %s (%s)", loadBalancerName, aws.StringValue(state.State), aws.StringValue(state.Description)), nil
+		}
+	}
+	return "", nil
+}
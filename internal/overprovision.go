@@ -0,0 +1,185 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/client"
+	"github.com/aws/aws-sdk-go/service/ecs"
+	"github.com/pkg/errors"
+)
+
+// OverProvisionServices raises the desired count of each service with tasks on
+// the drained instance by the number of tasks it has there, and returns true
+// once that many tasks of each service are RUNNING on other instances.  It's
+// meant to be called on every poll iteration.  The first call only records the
+// original desired counts in params, so that they're in the execution state
+// before any is changed; later calls change them, retrying any change that
+// failed, and return as soon as any has been made so that it's recorded too.
+//
+// The scheduler may place the extra tasks on the drained instance itself, as
+// it's still ACTIVE; if so they won't count, and the drain waits until the
+// deadline.  Spread placement strategies make this unlikely.
+func OverProvisionServices(ctx context.Context, sess client.ConfigProvider, params *DrainParameters) (bool, error) {
+	if params.ServiceOverProvisions == nil {
+		if err := planOverProvisions(ctx, sess, params); err != nil {
+			return false, err
+		}
+		return false, nil
+	}
+
+	client := ecs.New(sess)
+	pending, applied := false, false
+	for i := range params.ServiceOverProvisions {
+		op := &params.ServiceOverProvisions[i]
+		if op.Applied {
+			continue
+		}
+		fmt.Printf("Raising desired count of service %s from %d to %d\n", op.Service, op.OriginalDesiredCount, op.TargetDesiredCount)
+		if _, err := client.UpdateServiceWithContext(
+			ctx,
+			&ecs.UpdateServiceInput{
+				Cluster:      aws.String(params.ECSCluster),
+				Service:      aws.String(op.Service),
+				DesiredCount: aws.Int64(op.TargetDesiredCount),
+			},
+		); err != nil {
+			// The original count is already recorded, so try again next time
+			fmt.Printf("Failed to raise desired count of service %s: %v\n", op.Service, err)
+			pending = true
+			continue
+		}
+		op.Applied = true
+		applied = true
+	}
+	if pending || applied {
+		return false, nil
+	}
+
+	ready := true
+	for _, op := range params.ServiceOverProvisions {
+		running, err := runningTasksElsewhere(ctx, sess, params.ECSCluster, op.Service, params.ECSInstanceID)
+		if err != nil {
+			return false, err
+		}
+		fmt.Printf("Service %s: %d of %d tasks running on other instances\n", op.Service, running, op.OriginalDesiredCount)
+		if running < op.OriginalDesiredCount {
+			ready = false
+		}
+	}
+	return ready, nil
+}
+
+// RestoreDesiredCounts sets each service planned by OverProvisionServices back
+// to its original desired count.  Services whose change isn't recorded as
+// Applied are restored too, as an invocation may have been cut short after
+// changing them.
+func RestoreDesiredCounts(ctx context.Context, sess client.ConfigProvider, params *DrainParameters) error {
+	client := ecs.New(sess)
+	for i := range params.ServiceOverProvisions {
+		op := &params.ServiceOverProvisions[i]
+		if op.Restored {
+			continue
+		}
+		fmt.Printf("Restoring desired count of service %s to %d\n", op.Service, op.OriginalDesiredCount)
+		if _, err := client.UpdateServiceWithContext(
+			ctx,
+			&ecs.UpdateServiceInput{
+				Cluster:      aws.String(params.ECSCluster),
+				Service:      aws.String(op.Service),
+				DesiredCount: aws.Int64(op.OriginalDesiredCount),
+			},
+		); err != nil {
+			return errors.WithMessage(err, "UpdateService")
+		}
+		op.Restored = true
+	}
+	return nil
+}
+
+// planOverProvisions records the current and raised desired counts of each
+// non-daemon service with tasks on the drained instance.
+func planOverProvisions(ctx context.Context, sess client.ConfigProvider, params *DrainParameters) error {
+	counts := make(map[string]int64)
+	var names []string
+	if err := DescribeInstanceTasks(ctx, sess, params.ECSCluster, params.ECSInstanceID,
+		func(tasks []*ecs.Task, failures []*ecs.Failure) bool {
+			for _, task := range tasks {
+				name := TaskServiceName(task)
+				if name == "" {
+					continue
+				}
+				if counts[name] == 0 {
+					names = append(names, name)
+				}
+				counts[name]++
+			}
+			return true
+		},
+	); err != nil {
+		return err
+	}
+
+	services, _, err := DescribeServices(ctx, sess, params.ECSCluster, names)
+	if err != nil {
+		return err
+	}
+	params.ServiceOverProvisions = []ServiceOverProvision{}
+	for _, service := range services {
+		name := aws.StringValue(service.ServiceName)
+		if aws.StringValue(service.SchedulingStrategy) == "DAEMON" || aws.StringValue(service.Status) != "ACTIVE" {
+			continue
+		}
+		desired := aws.Int64Value(service.DesiredCount)
+		params.ServiceOverProvisions = append(params.ServiceOverProvisions, ServiceOverProvision{
+			Service:              name,
+			OriginalDesiredCount: desired,
+			TargetDesiredCount:   desired + counts[name],
+		})
+	}
+	return nil
+}
+
+// runningTasksElsewhere returns the number of the service's tasks that are
+// RUNNING on container instances other than the given one.
+func runningTasksElsewhere(ctx context.Context, sess client.ConfigProvider, cluster, service, ecsInstanceID string) (int64, error) {
+	var (
+		count    int64
+		innerErr error
+	)
+	client := ecs.New(sess)
+	if err := client.ListTasksPagesWithContext(
+		ctx,
+		&ecs.ListTasksInput{
+			Cluster:       aws.String(cluster),
+			ServiceName:   aws.String(service),
+			DesiredStatus: aws.String("RUNNING"),
+		},
+		func(page *ecs.ListTasksOutput, lastPage bool) bool {
+			if len(page.TaskArns) == 0 {
+				return false // nothing to do
+			}
+			var tasks *ecs.DescribeTasksOutput
+			tasks, innerErr = client.DescribeTasksWithContext(
+				ctx,
+				&ecs.DescribeTasksInput{
+					Cluster: aws.String(cluster),
+					Tasks:   page.TaskArns,
+				},
+			)
+			if innerErr != nil {
+				return false
+			}
+			for _, task := range tasks.Tasks {
+				if aws.StringValue(task.LastStatus) == "RUNNING" && aws.StringValue(task.ContainerInstanceArn) != ecsInstanceID {
+					count++
+				}
+			}
+			return !lastPage
+		},
+	); err != nil {
+		return 0, errors.WithMessage(err, "ListTasks")
+	}
+	return count, errors.WithMessage(innerErr, "DescribeTasks")
+}
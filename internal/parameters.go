@@ -1,5 +1,10 @@
 package internal
 
+import (
+	"encoding/json"
+	"fmt"
+)
+
 type CloudwatchLifecycleEvent struct {
 	Detail AutoScalingLifecycleEvent `json:"detail"`
 }
@@ -22,19 +27,158 @@ type BaseParameters struct {
 	ECSInstanceID         string
 	RunningExecutionCount int
 	Params                map[string]string
+
+	// ECSClusters are searched for the instance while ECSCluster is
+	// unknown.  AllClusters searches every cluster in the region.
+	ECSClusters []string `json:",omitempty"`
+
+	// Partial is set by a handler that ran out of time before it could
+	// finish.  Its result must not be acted upon; poll again instead.
+	Partial bool
 }
 
 type DrainParameters struct {
 	AutoScalingLifecycleEvent
 	BaseParameters
-	ECSTaskCount int
+	ECSTaskCount    int
+	TaskStops       TaskStopReport
+	StopSelector    *TaskSelector `json:",omitempty"`
+	CountSelector   *TaskSelector `json:",omitempty"`
+	StopConcurrency int
+	DrainPlan       []DrainStage       `json:",omitempty"`
+	DrainProgress   []DrainStageStatus `json:",omitempty"`
+
+	// Tasks belonging to DAEMON services, or to one of the listed task
+	// families, never leave a draining instance, so they aren't counted.
+	IgnoreDaemonServiceTasks bool
+	IgnoredTaskFamilies      []string      `json:",omitempty"`
+	IgnoredTasks             []TaskOutcome `json:",omitempty"`
+
+	// If WaitForServiceReplacements is set, the drain isn't complete until
+	// the services that had tasks on the instance have settled elsewhere.
+	WaitForServiceReplacements bool
+	DrainedServices            []string `json:",omitempty"`
+	ServicesSettled            bool
+	UnsettledServices          []string `json:",omitempty"`
+
+	// If CheckCapacity is set, the instance isn't set to DRAINING until
+	// the rest of the cluster can absorb its service tasks.
+	CheckCapacity       bool
+	ScaleOutForCapacity bool
+	CapacityShortfall   []string `json:",omitempty"`
+	ScaledOut           bool
+	Draining            bool
+
+	// If OverProvision is set, services with tasks on the instance have
+	// their desired count raised before it's drained, and restored once the
+	// drain is over.
+	OverProvision         bool
+	ServiceOverProvisions []ServiceOverProvision `json:",omitempty"`
+
+	// ProtectedTasksPending is set while tasks chosen by the stop selector
+	// are kept from stopping by scale-in protection.  ProtectedTaskCount
+	// is the number of protected tasks holding up the drain.
+	ProtectedTasksPending bool
+	ProtectedTaskCount    int
+
+	// If DeregisterInstance is set, the container instance is deregistered
+	// from the cluster once the drain succeeds.  A failure to deregister it
+	// is recorded in DeregisterError but doesn't hold up termination.
+	DeregisterInstance bool
+	ForceDeregister    bool
+	Deregistered       bool
+	DeregisterError    string `json:",omitempty"`
+
+	AgentParameters
+
+	// DrainCommand is run on the instance with SSM Run Command before it's
+	// drained.  The drain goes ahead once DrainCommandDone is set, even if
+	// the command failed, as recorded in DrainCommandRun, or hadn't finished
+	// by DrainCommandGiveUp.
+	DrainCommand       *CommandSpec `json:",omitempty"`
+	DrainCommandRun    *CommandRun  `json:",omitempty"`
+	DrainCommandGiveUp string       `json:",omitempty"`
+	DrainCommandDone   bool
+}
+
+// ServiceOverProvision records a temporary change to a service's desired
+// count, so that it can be undone even if a step is retried.
+type ServiceOverProvision struct {
+	Service              string
+	OriginalDesiredCount int64
+	TargetDesiredCount   int64
+	Applied              bool
+	Restored             bool
+}
+
+// TaskStopReport summarizes an attempt to stop the tasks on an ECS instance.
+// Protected lists the matched tasks that weren't stopped because they have
+// scale-in protection.
+type TaskStopReport struct {
+	Matched   int
+	Stopped   []string
+	Protected []TaskOutcome
+	Skipped   []TaskOutcome
+	Failed    []TaskOutcome
+}
+
+// TaskOutcome explains why a task was skipped or could not be stopped.
+type TaskOutcome struct {
+	TaskARN string
+	Reason  string
+}
+
+// Merge adds the contents of other, a later attempt at stopping the same
+// tasks, to r.  A later attempt only matches tasks that an earlier one did, so
+// the first count of matched tasks is kept.  Protected tasks are retried on
+// later attempts, so r's are replaced by those of other.
+func (r *TaskStopReport) Merge(other TaskStopReport) {
+	if r.Matched == 0 {
+		r.Matched = other.Matched
+	}
+	r.Stopped = append(r.Stopped, other.Stopped...)
+	r.Protected = other.Protected
+	r.Skipped = append(r.Skipped, other.Skipped...)
+	r.Failed = append(r.Failed, other.Failed...)
+}
+
+func (r TaskStopReport) String() string {
+	return fmt.Sprintf("%d matched, %d stopped, %d protected, %d skipped, %d failed",
+		r.Matched, len(r.Stopped), len(r.Protected), len(r.Skipped), len(r.Failed))
 }
 
 type ECSReadyParameters struct {
 	AutoScalingLifecycleEvent
 	BaseParameters
 	RequiredTaskFamilies []string
+	Readiness            *ReadinessSpec `json:",omitempty"`
+	UnmetRequirements    []string       `json:",omitempty"`
 	Ready                bool
+
+	// Quarantine is the way tasks are kept off the instance until it's
+	// ready, if at all.  Quarantined is set while they are.
+	Quarantine  string `json:",omitempty"`
+	Quarantined bool
+
+	// If CheckBaseline is set, the instance must pass its EC2 status checks
+	// and have its SSM agent online before anything else is checked.
+	// BaselineReady is set once it has.
+	CheckBaseline bool
+	BaselineReady bool
+}
+
+// CheckParameters are passed between the steps of a generic readiness poll.
+type CheckParameters struct {
+	AutoScalingLifecycleEvent
+	BaseParameters
+	InternalIPAddr string
+	Check          CheckSpec
+	Result         *Result `json:",omitempty"`
+	Ready          bool
+
+	// CheckState holds the state each check keeps between iterations,
+	// keyed by check name.
+	CheckState map[string]json.RawMessage `json:",omitempty"`
 }
 
 type KafkaReadyParameters struct {
@@ -43,4 +187,60 @@ type KafkaReadyParameters struct {
 	InternalIPAddr string
 	KafkaPort      int
 	Ready          bool
+	// See ECSReadyParameters
+	CheckBaseline bool
+	BaselineReady bool
+}
+
+// LBDrainParameters are passed between the steps of a load balancer drain.
+// Pending describes the registrations whose connections are still draining.
+type LBDrainParameters struct {
+	AutoScalingLifecycleEvent
+	BaseParameters
+	TargetGroupARNs   []string `json:",omitempty"`
+	LoadBalancerNames []string `json:",omitempty"`
+	Deregistered      bool
+	Pending           []string `json:",omitempty"`
+	Drained           bool
+	AgentParameters
+}
+
+// AgentParameters are shared by the drain workflows.  If WaitForAgent is set,
+// the drain isn't complete until the lifecycle agent on the instance has run
+// its hooks for the Terminated state.  Agent is the outcome it reported.
+type AgentParameters struct {
+	WaitForAgent bool
+	Agent        *AgentResult `json:",omitempty"`
+}
+
+// AgentResult is the output of the check-lifecycle-agent step.  Status is
+// AgentDone or AgentFailed once the agent has Finished.
+type AgentResult struct {
+	Finished bool
+	Status   string
+}
+
+// CallbackParameters are passed between the steps of a readiness callback,
+// in which the instance reports its own readiness with a task token published
+// as the TokenParameterName SSM parameter.  TimeoutSeconds bounds the wait
+// for its signal.
+type CallbackParameters struct {
+	AutoScalingLifecycleEvent
+	BaseParameters
+	TokenParameterName string
+	TimeoutSeconds     int
+	Callback           *CallbackResult `json:",omitempty"`
+}
+
+// CallbackResult is the output an instance sends with SendTaskSuccess.
+type CallbackResult struct {
+	Ready  bool
+	Reason string `json:",omitempty"`
+}
+
+// TaskTokenRequest is the payload of the task that waits for an instance's
+// readiness callback.
+type TaskTokenRequest struct {
+	TaskToken string
+	Input     CallbackParameters
 }
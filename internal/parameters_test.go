@@ -0,0 +1,29 @@
+package internal
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTaskStopReportMerge(t *testing.T) {
+	first := TaskStopReport{
+		Matched:   4,
+		Stopped:   []string{"1", "2"},
+		Protected: []TaskOutcome{{TaskARN: "3", Reason: "scale-in protected"}},
+		Failed:    []TaskOutcome{{TaskARN: "4", Reason: "throttled"}},
+	}
+	// Retrying matches the protected and failed tasks again
+	retry := TaskStopReport{
+		Matched:   2,
+		Stopped:   []string{"3", "4"},
+		Protected: []TaskOutcome{},
+	}
+
+	var report TaskStopReport
+	report.Merge(first)
+	report.Merge(retry)
+	assert.Equal(t, 4, report.Matched)
+	assert.Equal(t, []string{"1", "2", "3", "4"}, report.Stopped)
+	assert.Empty(t, report.Protected)
+}
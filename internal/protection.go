@@ -0,0 +1,51 @@
+package internal
+
+import (
+	"context"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/client"
+	"github.com/aws/aws-sdk-go/service/ecs"
+	"github.com/pkg/errors"
+)
+
+// ProtectedTasks returns the tasks among taskARNs that have scale-in
+// protection enabled, mapped to the time their protection expires.  Tasks
+// that no longer exist are treated as unprotected.
+func ProtectedTasks(ctx context.Context, sess client.ConfigProvider, cluster string, taskARNs []string) (map[string]time.Time, error) {
+	protected := make(map[string]time.Time)
+	client := ecs.New(sess)
+	// GetTaskProtection accepts at most 10 tasks
+	for i := 0; i < len(taskARNs); i += 10 {
+		end := i + 10
+		if end > len(taskARNs) {
+			end = len(taskARNs)
+		}
+		result, err := client.GetTaskProtectionWithContext(
+			ctx,
+			&ecs.GetTaskProtectionInput{
+				Cluster: aws.String(cluster),
+				Tasks:   aws.StringSlice(taskARNs[i:end]),
+			},
+		)
+		if err != nil {
+			return nil, errors.WithMessage(err, "GetTaskProtection")
+		}
+		for _, task := range result.ProtectedTasks {
+			if aws.BoolValue(task.ProtectionEnabled) {
+				protected[aws.StringValue(task.TaskArn)] = aws.TimeValue(task.ExpirationDate)
+			}
+		}
+	}
+	return protected, nil
+}
+
+// taskARNs returns the ARNs of tasks.
+func taskARNs(tasks []*ecs.Task) []string {
+	arns := make([]string, len(tasks))
+	for i, task := range tasks {
+		arns[i] = aws.StringValue(task.TaskArn)
+	}
+	return arns
+}
@@ -0,0 +1,70 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws/client"
+	"github.com/pkg/errors"
+)
+
+// Ways of keeping tasks off a new container instance until it's ready.
+const (
+	// QuarantineDraining sets the instance to DRAINING.  Nothing can be
+	// placed on it, including the tasks of DAEMON services.
+	QuarantineDraining = "DRAINING"
+	// QuarantineAttribute sets QuarantineAttributeName on the instance.
+	// Only services with a placement constraint such as
+	// "attribute:ecs-instance-ready.quarantined !exists" stay off it.
+	QuarantineAttribute = "ATTRIBUTE"
+)
+
+// QuarantineAttributeName is the container instance attribute set while an
+// instance is quarantined with QuarantineAttribute.
+const QuarantineAttributeName = "ecs-instance-ready.quarantined"
+
+// ParseQuarantine validates a quarantine mode.  An empty string means no
+// quarantine.
+func ParseQuarantine(s string) (string, error) {
+	switch mode := strings.ToUpper(strings.TrimSpace(s)); mode {
+	case "", QuarantineDraining, QuarantineAttribute:
+		return mode, nil
+	default:
+		return "", fmt.Errorf("unknown quarantine mode %q", s)
+	}
+}
+
+// Quarantine keeps tasks off the container instance by the given mode.
+func Quarantine(ctx context.Context, sess client.ConfigProvider, cluster, ecsInstanceID, mode string) error {
+	fmt.Printf("Quarantining ECS instance %s on cluster %s (%s)\n", ecsInstanceID, cluster, mode)
+	switch mode {
+	case QuarantineDraining:
+		return SetContainerInstanceStatus(ctx, sess, cluster, ecsInstanceID, "DRAINING")
+	case QuarantineAttribute:
+		return PutInstanceAttribute(ctx, sess, cluster, ecsInstanceID, QuarantineAttributeName, "true")
+	}
+	return errors.Errorf("unknown quarantine mode %q", mode)
+}
+
+// LiftQuarantine undoes Quarantine.
+func LiftQuarantine(ctx context.Context, sess client.ConfigProvider, cluster, ecsInstanceID, mode string) error {
+	fmt.Printf("Lifting quarantine of ECS instance %s on cluster %s (%s)\n", ecsInstanceID, cluster, mode)
+	switch mode {
+	case QuarantineDraining:
+		return SetContainerInstanceStatus(ctx, sess, cluster, ecsInstanceID, "ACTIVE")
+	case QuarantineAttribute:
+		return DeleteInstanceAttribute(ctx, sess, cluster, ecsInstanceID, QuarantineAttributeName)
+	}
+	return errors.Errorf("unknown quarantine mode %q", mode)
+}
+
+// ValidateQuarantine returns an error if the instance couldn't become ready
+// while quarantined in the given mode.  No tasks can start on a DRAINING
+// instance, so it could never meet task requirements.
+func ValidateQuarantine(mode string, requiredTaskFamilies []string, readiness *ReadinessSpec) error {
+	if mode == QuarantineDraining && (len(requiredTaskFamilies) > 0 || len(readiness.Requirements()) > 0) {
+		return errors.New("DRAINING quarantine can't be combined with required tasks or daemon services; use ATTRIBUTE")
+	}
+	return nil
+}
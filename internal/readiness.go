@@ -0,0 +1,297 @@
+package internal
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ecs"
+	"github.com/pkg/errors"
+)
+
+// ReadinessSpec lists the tasks that must be running on an ECS instance
+// before it's considered ready.  Only tasks whose last status is RUNNING
+// count.
+//
+// Specs are configured as JSON, for example:
+//
+//	{
+//	  "Tasks": [
+//	    {"Family": "log-router", "Healthy": true},
+//	    {"Service": "web", "MinCount": 2}
+//	  ],
+//	  "DaemonServices": ["node-exporter"],
+//	  "Host": {"MinAgentVersion": "1.30.0", "Attributes": ["ecs.capability.efs"]}
+//	}
+type ReadinessSpec struct {
+	Tasks []TaskRequirement `json:",omitempty"`
+	// DaemonServices must each have a task on the instance.
+	DaemonServices []string `json:",omitempty"`
+	// Host lists requirements of the container instance itself.
+	Host *HostCriteria `json:",omitempty"`
+}
+
+// HostCriteria are requirements of a container instance, as registered with
+// ECS.  Empty fields aren't checked.
+type HostCriteria struct {
+	MinAgentVersion  string `json:",omitempty"`
+	MinDockerVersion string `json:",omitempty"`
+	// Attributes are required attribute names, or "name=value" pairs.
+	// Names and values are patterns, as in a TaskRule.
+	Attributes []string `json:",omitempty"`
+	// MinCPU is in CPU units and MinMemory in MiB.
+	MinCPU    int64 `json:",omitempty"`
+	MinMemory int64 `json:",omitempty"`
+	// NoPendingAgentUpdate requires that no agent update is in progress.
+	NoPendingAgentUpdate bool `json:",omitempty"`
+}
+
+// TaskRequirement requires a minimum number of tasks of a task family or
+// service.  Exactly one of Family and Service must be given.
+type TaskRequirement struct {
+	Family  string `json:",omitempty"`
+	Service string `json:",omitempty"`
+	// MinCount defaults to 1.
+	MinCount int `json:",omitempty"`
+	// Healthy requires the tasks' health status to be HEALTHY, meaning
+	// that every essential container with a health check passes it.
+	Healthy bool `json:",omitempty"`
+}
+
+// ParseReadinessSpec parses a JSON-encoded readiness spec.  It returns nil if
+// s is empty.
+func ParseReadinessSpec(s string) (*ReadinessSpec, error) {
+	if strings.TrimSpace(s) == "" {
+		return nil, nil
+	}
+	spec := &ReadinessSpec{}
+	dec := json.NewDecoder(bytes.NewBufferString(s))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(spec); err != nil {
+		return nil, errors.WithMessage(err, "invalid readiness spec")
+	}
+	if err := spec.Validate(); err != nil {
+		return nil, err
+	}
+	return spec, nil
+}
+
+// Validate returns an error if the spec is malformed.  A nil spec is valid.
+func (s *ReadinessSpec) Validate() error {
+	if s == nil {
+		return nil
+	}
+	if err := s.Host.validate(); err != nil {
+		return errors.WithMessage(err, "readiness spec host criteria")
+	}
+	for i, req := range s.Tasks {
+		if (req.Family == "") == (req.Service == "") {
+			return fmt.Errorf("readiness spec task %d: exactly one of Family and Service is required", i)
+		}
+		if req.MinCount < 0 {
+			return fmt.Errorf("readiness spec task %d: MinCount must not be negative", i)
+		}
+	}
+	return nil
+}
+
+// Requirements returns the spec's task requirements, including one for each
+// daemon service.  A nil spec has none.
+func (s *ReadinessSpec) Requirements() []TaskRequirement {
+	if s == nil {
+		return nil
+	}
+	reqs := append([]TaskRequirement{}, s.Tasks...)
+	for _, service := range s.DaemonServices {
+		reqs = append(reqs, TaskRequirement{Service: service})
+	}
+	return reqs
+}
+
+// UnmetRequirements returns a description of each requirement that tasks
+// don't meet.
+func UnmetRequirements(reqs []TaskRequirement, tasks []*ecs.Task) []string {
+	var unmet []string
+	for _, req := range reqs {
+		count := 0
+		for _, task := range tasks {
+			if req.Matches(task) {
+				count++
+			}
+		}
+		min := req.MinCount
+		if min == 0 {
+			min = 1
+		}
+		if count < min {
+			unmet = append(unmet, fmt.Sprintf("%s: %d of %d tasks", req, count, min))
+		}
+	}
+	return unmet
+}
+
+// Matches returns true if task counts towards the requirement.
+func (r TaskRequirement) Matches(task *ecs.Task) bool {
+	if aws.StringValue(task.LastStatus) != "RUNNING" {
+		return false
+	}
+	if r.Healthy && aws.StringValue(task.HealthStatus) != "HEALTHY" {
+		return false
+	}
+	if r.Family != "" {
+		return TaskFamily(task) == r.Family
+	}
+	return TaskServiceName(task) == r.Service
+}
+
+func (r TaskRequirement) String() string {
+	s := "family " + r.Family
+	if r.Service != "" {
+		s = "service " + r.Service
+	}
+	if r.Healthy {
+		s += " (healthy)"
+	}
+	return s
+}
+
+// Unmet returns a description of each criterion that the container instance
+// doesn't meet, prefixed by the criterion's name.  A nil HostCriteria has no
+// criteria.
+func (h *HostCriteria) Unmet(instance *ecs.ContainerInstance) []string {
+	if h == nil {
+		return nil
+	}
+	var unmet []string
+	if h.MinAgentVersion != "" {
+		version := ""
+		if instance.VersionInfo != nil {
+			version = aws.StringValue(instance.VersionInfo.AgentVersion)
+		}
+		if compareVersions(version, h.MinAgentVersion) < 0 {
+			unmet = append(unmet, fmt.Sprintf("MinAgentVersion: agent version %q is older than %s", version, h.MinAgentVersion))
+		}
+	}
+	if h.MinDockerVersion != "" {
+		version := ""
+		if instance.VersionInfo != nil {
+			// e.g. "DockerVersion: 19.03.6-ce"
+			version = aws.StringValue(instance.VersionInfo.DockerVersion)
+			version = version[strings.LastIndex(version, " ")+1:]
+		}
+		if compareVersions(version, h.MinDockerVersion) < 0 {
+			unmet = append(unmet, fmt.Sprintf("MinDockerVersion: Docker version %q is older than %s", version, h.MinDockerVersion))
+		}
+	}
+	for _, attribute := range h.Attributes {
+		if !hasAttribute(instance, attribute) {
+			unmet = append(unmet, fmt.Sprintf("Attributes: no attribute matching %q", attribute))
+		}
+	}
+	var cpu, memory int64
+	for _, resource := range instance.RegisteredResources {
+		switch aws.StringValue(resource.Name) {
+		case "CPU":
+			cpu = aws.Int64Value(resource.IntegerValue)
+		case "MEMORY":
+			memory = aws.Int64Value(resource.IntegerValue)
+		}
+	}
+	if cpu < h.MinCPU {
+		unmet = append(unmet, fmt.Sprintf("MinCPU: %d CPU units registered, need %d", cpu, h.MinCPU))
+	}
+	if memory < h.MinMemory {
+		unmet = append(unmet, fmt.Sprintf("MinMemory: %d MiB registered, need %d", memory, h.MinMemory))
+	}
+	if h.NoPendingAgentUpdate {
+		switch status := aws.StringValue(instance.AgentUpdateStatus); status {
+		case "PENDING", "STAGING", "STAGED", "UPDATING":
+			unmet = append(unmet, fmt.Sprintf("NoPendingAgentUpdate: agent update is %s", status))
+		}
+	}
+	return unmet
+}
+
+func (h *HostCriteria) validate() error {
+	if h == nil {
+		return nil
+	}
+	for _, version := range []string{h.MinAgentVersion, h.MinDockerVersion} {
+		if version != "" && parseVersion(version) == nil {
+			return fmt.Errorf("invalid version %q", version)
+		}
+	}
+	for _, attribute := range h.Attributes {
+		for _, pattern := range strings.SplitN(attribute, "=", 2) {
+			if _, err := compilePattern(pattern); err != nil {
+				return errors.WithMessage(err, fmt.Sprintf("attribute %q", attribute))
+			}
+		}
+	}
+	return nil
+}
+
+// hasAttribute returns true if the container instance has an attribute
+// matching "name" or "name=value".
+func hasAttribute(instance *ecs.ContainerInstance, attribute string) bool {
+	parts := strings.SplitN(attribute, "=", 2)
+	for _, attr := range instance.Attributes {
+		if !matchPattern(parts[0], aws.StringValue(attr.Name)) {
+			continue
+		}
+		if len(parts) == 1 || matchPattern(parts[1], aws.StringValue(attr.Value)) {
+			return true
+		}
+	}
+	return false
+}
+
+// compareVersions compares dotted numeric versions, ignoring any suffix such
+// as "-ce".  A version that can't be parsed is older than any other.
+func compareVersions(a, b string) int {
+	va, vb := parseVersion(a), parseVersion(b)
+	if va == nil || vb == nil {
+		if va != nil {
+			return 1
+		}
+		if vb != nil {
+			return -1
+		}
+		return 0
+	}
+	for i := 0; i < len(va) || i < len(vb); i++ {
+		var x, y int
+		if i < len(va) {
+			x = va[i]
+		}
+		if i < len(vb) {
+			y = vb[i]
+		}
+		if x != y {
+			if x < y {
+				return -1
+			}
+			return 1
+		}
+	}
+	return 0
+}
+
+func parseVersion(s string) []int {
+	s = strings.TrimPrefix(s, "v")
+	if i := strings.IndexAny(s, "-+ "); i >= 0 {
+		s = s[:i]
+	}
+	var version []int
+	for _, part := range strings.Split(s, ".") {
+		n, err := strconv.Atoi(part)
+		if err != nil {
+			return nil
+		}
+		version = append(version, n)
+	}
+	return version
+}
@@ -0,0 +1,83 @@
+package internal
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ecs"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHostCriteriaUnmet(t *testing.T) {
+	instance := &ecs.ContainerInstance{
+		VersionInfo: &ecs.VersionInfo{
+			AgentVersion:  aws.String("1.68.2"),
+			DockerVersion: aws.String("DockerVersion: 20.10.13-ce"),
+		},
+		Attributes: []*ecs.Attribute{
+			{Name: aws.String("ecs.capability.efsAuth")},
+			{Name: aws.String("ecs.instance-type"), Value: aws.String("m5.large")},
+		},
+		RegisteredResources: []*ecs.Resource{
+			{Name: aws.String("CPU"), IntegerValue: aws.Int64(2048)},
+			{Name: aws.String("MEMORY"), IntegerValue: aws.Int64(7680)},
+		},
+		AgentUpdateStatus: aws.String("UPDATED"),
+	}
+
+	for _, test := range []struct {
+		name     string
+		criteria *HostCriteria
+		unmet    []string
+	}{
+		{"nil", nil, nil},
+		{"empty", &HostCriteria{}, nil},
+		{"met", &HostCriteria{
+			MinAgentVersion:      "1.68",
+			MinDockerVersion:     "20.10.13",
+			Attributes:           []string{"ecs.capability.efsAuth", "ecs.instance-type=m5.*"},
+			MinCPU:               2048,
+			MinMemory:            7680,
+			NoPendingAgentUpdate: true,
+		}, nil},
+		{"old agent", &HostCriteria{MinAgentVersion: "1.70.0"},
+			[]string{`MinAgentVersion: agent version "1.68.2" is older than 1.70.0`}},
+		{"old Docker", &HostCriteria{MinDockerVersion: "v20.10.14"},
+			[]string{`MinDockerVersion: Docker version "20.10.13-ce" is older than v20.10.14`}},
+		{"missing attribute", &HostCriteria{Attributes: []string{"ecs.capability.efsAuth", "ecs.capability.fsx"}},
+			[]string{`Attributes: no attribute matching "ecs.capability.fsx"`}},
+		{"attribute value", &HostCriteria{Attributes: []string{"ecs.instance-type=c5.*"}},
+			[]string{`Attributes: no attribute matching "ecs.instance-type=c5.*"`}},
+		{"too small", &HostCriteria{MinCPU: 4096, MinMemory: 8192},
+			[]string{"MinCPU: 2048 CPU units registered, need 4096", "MinMemory: 7680 MiB registered, need 8192"}},
+	} {
+		assert.Equal(t, test.unmet, test.criteria.Unmet(instance), test.name)
+	}
+}
+
+func TestHostCriteriaUnmetWithoutVersionInfo(t *testing.T) {
+	criteria := &HostCriteria{MinAgentVersion: "1.0.0", NoPendingAgentUpdate: true}
+	instance := &ecs.ContainerInstance{AgentUpdateStatus: aws.String("STAGING")}
+	assert.Equal(t, []string{
+		`MinAgentVersion: agent version "" is older than 1.0.0`,
+		"NoPendingAgentUpdate: agent update is STAGING",
+	}, criteria.Unmet(instance))
+}
+
+func TestCompareVersions(t *testing.T) {
+	for _, test := range []struct {
+		a, b string
+		want int
+	}{
+		{"1.2.3", "1.2.3", 0},
+		{"1.2", "1.2.0", 0},
+		{"1.10.0", "1.9.9", 1},
+		{"1.2.3", "1.2.4", -1},
+		{"v20.10.13", "20.10.13-ce", 0},
+		{"", "1.0.0", -1},
+		{"1.0.0", "unknown", 1},
+		{"", "unknown", 0},
+	} {
+		assert.Equal(t, test.want, compareVersions(test.a, test.b), "%q vs %q", test.a, test.b)
+	}
+}
@@ -0,0 +1,179 @@
+package internal
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/client"
+	"github.com/aws/aws-sdk-go/service/ssm"
+	"github.com/pkg/errors"
+)
+
+const (
+	// DefaultCommandDocument runs shell commands on Linux instances.
+	DefaultCommandDocument = "AWS-RunShellScript"
+
+	// DefaultOutputLimit is the number of bytes of a command's stdout and
+	// stderr that are kept.
+	DefaultOutputLimit = 1024
+
+	// DefaultDeliveryTimeoutSeconds is how long a command waits for the
+	// instance to pick it up before it times out; 30 is the least SSM
+	// allows.
+	DefaultDeliveryTimeoutSeconds = 60
+)
+
+// CommandSpec is a command to be run on an instance with SSM Run Command.
+// Commands is shorthand for the "commands" parameter of the shell script
+// documents, whose execution is limited to TimeoutSeconds if it's set.  A
+// command the instance hasn't picked up within DeliveryTimeoutSeconds, e.g.
+// because its SSM agent is offline, times out.
+type CommandSpec struct {
+	Document               string              `json:",omitempty"`
+	Commands               []string            `json:",omitempty"`
+	Parameters             map[string][]string `json:",omitempty"`
+	TimeoutSeconds         int                 `json:",omitempty"`
+	DeliveryTimeoutSeconds int                 `json:",omitempty"`
+	OutputLimit            int                 `json:",omitempty"`
+}
+
+// CommandRun records the progress of a command between poll iterations.
+// Stdout and Stderr hold the end of the command's output, up to the spec's
+// OutputLimit.
+type CommandRun struct {
+	CommandID string
+	Status    string `json:",omitempty"`
+	ExitCode  int64
+	Stdout    string `json:",omitempty"`
+	Stderr    string `json:",omitempty"`
+	Done      bool
+	Succeeded bool
+}
+
+// ParseCommandSpec parses a JSON command spec.  It returns nil if s is empty.
+func ParseCommandSpec(s string) (*CommandSpec, error) {
+	if strings.TrimSpace(s) == "" {
+		return nil, nil
+	}
+	spec := &CommandSpec{}
+	dec := json.NewDecoder(bytes.NewBufferString(s))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(spec); err != nil {
+		return nil, errors.WithMessage(err, "invalid command spec")
+	}
+	if err := spec.Validate(); err != nil {
+		return nil, err
+	}
+	return spec, nil
+}
+
+// Validate fills in defaults, and returns an error if the spec has nothing
+// to run.
+func (s *CommandSpec) Validate() error {
+	if s.Document == "" {
+		s.Document = DefaultCommandDocument
+	}
+	if s.OutputLimit == 0 {
+		s.OutputLimit = DefaultOutputLimit
+	}
+	if s.DeliveryTimeoutSeconds == 0 {
+		s.DeliveryTimeoutSeconds = DefaultDeliveryTimeoutSeconds
+	}
+	if len(s.Commands) == 0 && len(s.Parameters) == 0 {
+		return errors.New("command spec must have Commands or Parameters")
+	}
+	if s.TimeoutSeconds < 0 || s.OutputLimit < 0 {
+		return errors.New("TimeoutSeconds and OutputLimit must not be negative")
+	}
+	if s.DeliveryTimeoutSeconds < 30 {
+		return errors.New("DeliveryTimeoutSeconds must be at least 30")
+	}
+	return nil
+}
+
+// SendCommand starts the command on the EC2 instance, and returns its ID.
+func SendCommand(ctx context.Context, sess client.ConfigProvider, spec CommandSpec, ec2InstanceID string) (string, error) {
+	parameters := make(map[string][]*string)
+	for name, values := range spec.Parameters {
+		parameters[name] = aws.StringSlice(values)
+	}
+	if len(spec.Commands) > 0 {
+		parameters["commands"] = aws.StringSlice(spec.Commands)
+		if spec.TimeoutSeconds > 0 {
+			parameters["executionTimeout"] = aws.StringSlice([]string{strconv.Itoa(spec.TimeoutSeconds)})
+		}
+	}
+
+	result, err := ssm.New(sess).SendCommandWithContext(
+		ctx,
+		&ssm.SendCommandInput{
+			DocumentName:   aws.String(spec.Document),
+			InstanceIds:    aws.StringSlice([]string{ec2InstanceID}),
+			Parameters:     parameters,
+			TimeoutSeconds: aws.Int64(int64(spec.DeliveryTimeoutSeconds)),
+		},
+	)
+	if err != nil {
+		return "", errors.WithMessage(err, "SendCommand")
+	}
+	return aws.StringValue(result.Command.CommandId), nil
+}
+
+// PollCommand updates run with the status of its command on the EC2
+// instance.  The command succeeded if it exited with code 0.
+func PollCommand(ctx context.Context, sess client.ConfigProvider, spec CommandSpec, ec2InstanceID string, run *CommandRun) error {
+	result, err := ssm.New(sess).GetCommandInvocationWithContext(
+		ctx,
+		&ssm.GetCommandInvocationInput{
+			CommandId:  aws.String(run.CommandID),
+			InstanceId: aws.String(ec2InstanceID),
+		},
+	)
+	if err != nil {
+		// The invocation isn't visible until shortly after it's sent
+		if aerr, ok := err.(awserr.Error); ok && aerr.Code() == ssm.ErrCodeInvocationDoesNotExist {
+			run.Status = ssm.CommandInvocationStatusPending
+			return nil
+		}
+		return errors.WithMessage(err, "GetCommandInvocation")
+	}
+
+	run.Status = aws.StringValue(result.Status)
+	run.ExitCode = aws.Int64Value(result.ResponseCode)
+	run.Stdout = truncateOutput(aws.StringValue(result.StandardOutputContent), spec.OutputLimit)
+	run.Stderr = truncateOutput(aws.StringValue(result.StandardErrorContent), spec.OutputLimit)
+	switch run.Status {
+	case ssm.CommandInvocationStatusPending, ssm.CommandInvocationStatusInProgress,
+		ssm.CommandInvocationStatusDelayed, ssm.CommandInvocationStatusCancelling:
+		run.Done = false
+	default:
+		run.Done = true
+	}
+	run.Succeeded = run.Status == ssm.CommandInvocationStatusSuccess && run.ExitCode == 0
+	return nil
+}
+
+func (r CommandRun) String() string {
+	return fmt.Sprintf("command %s: %s (exit code %d)", r.CommandID, r.Status, r.ExitCode)
+}
+
+// truncateOutput keeps at most the last limit bytes of output, which is where
+// the reason for a failure is usually found.  The cut is moved forward to the
+// start of a character, so that none is split.
+func truncateOutput(output string, limit int) string {
+	if limit <= 0 || len(output) <= limit {
+		return output
+	}
+	cut := len(output) - limit
+	for cut < len(output) && !utf8.RuneStart(output[cut]) {
+		cut++
+	}
+	return "..." + output[cut:]
+}
@@ -0,0 +1,29 @@
+package internal
+
+import (
+	"testing"
+	"unicode/utf8"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTruncateOutput(t *testing.T) {
+	for _, test := range []struct {
+		name   string
+		output string
+		limit  int
+		want   string
+	}{
+		{"no limit", "hello", 0, "hello"},
+		{"short", "hello", 5, "hello"},
+		{"long", "hello world", 5, "...world"},
+		{"splits a character", "abcé", 1, "..."},
+		{"keeps a whole character", "abcé", 2, "...é"},
+		{"splits a wide character", "a€b€", 4, "...b€"},
+		{"keeps a wide character", "a€b€", 3, "...€"},
+	} {
+		got := truncateOutput(test.output, test.limit)
+		assert.Equal(t, test.want, got, test.name)
+		assert.True(t, utf8.ValidString(got), test.name)
+	}
+}
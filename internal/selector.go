@@ -0,0 +1,272 @@
+package internal
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ecs"
+	"github.com/pkg/errors"
+)
+
+// TaskSelector chooses ECS tasks by their attributes.  A task is selected if
+// it matches at least one Include rule and no Exclude rule.  A selector with
+// no Include rules selects nothing.
+//
+// Selectors are configured as JSON, for example:
+//
+//	{
+//	  "Include": [{"Group": "family:batch-*"}, {"StartedBy": "/^cron-/"}],
+//	  "Exclude": [{"Tags": {"drain": "skip"}}]
+//	}
+type TaskSelector struct {
+	Include []TaskRule `json:",omitempty"`
+	Exclude []TaskRule `json:",omitempty"`
+}
+
+// TaskRule matches a task if every non-empty field matches.  All fields but
+// LaunchType are patterns: a shell glob, or a regular expression if enclosed
+// in slashes.  In a glob, "*" matches any run of characters, "/" included, "?"
+// matches any one character, "[...]" matches a character class, and "\\"
+// quotes the character after it.  A pattern prefixed with "!" matches whatever
+// the rest of the pattern doesn't.
+type TaskRule struct {
+	// Group is the task group, e.g. "service:web" or "family:batch".
+	Group string `json:",omitempty"`
+	// Family is the task definition family.
+	Family string `json:",omitempty"`
+	// StartedBy is the tag set by whoever started the task.
+	StartedBy string `json:",omitempty"`
+	// LaunchType is matched exactly, e.g. "EC2".
+	LaunchType string `json:",omitempty"`
+	// Tags maps task tag keys to value patterns.  A task without the tag
+	// is treated as if its value were empty.
+	Tags map[string]string `json:",omitempty"`
+	// Container matches if any container in the task has a matching name.
+	Container string `json:",omitempty"`
+}
+
+// ParseTaskSelector parses a JSON-encoded task selector.  It returns nil if s
+// is empty.
+func ParseTaskSelector(s string) (*TaskSelector, error) {
+	if strings.TrimSpace(s) == "" {
+		return nil, nil
+	}
+	selector := &TaskSelector{}
+	dec := json.NewDecoder(bytes.NewBufferString(s))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(selector); err != nil {
+		return nil, errors.WithMessage(err, "invalid task selector")
+	}
+	if err := selector.Validate(); err != nil {
+		return nil, err
+	}
+	return selector, nil
+}
+
+// Validate returns an error if any pattern in the selector is malformed.
+func (s *TaskSelector) Validate() error {
+	for _, rules := range [][]TaskRule{s.Include, s.Exclude} {
+		for i, rule := range rules {
+			if err := rule.validate(); err != nil {
+				return errors.WithMessage(err, fmt.Sprintf("task selector rule %d", i))
+			}
+		}
+	}
+	return nil
+}
+
+// Matches returns true if the selector selects task.  A nil selector selects
+// nothing.
+func (s *TaskSelector) Matches(task *ecs.Task) bool {
+	if s == nil {
+		return false
+	}
+	included := false
+	for _, rule := range s.Include {
+		if rule.Matches(task) {
+			included = true
+			break
+		}
+	}
+	if !included {
+		return false
+	}
+	for _, rule := range s.Exclude {
+		if rule.Matches(task) {
+			return false
+		}
+	}
+	return true
+}
+
+// Matches returns true if every non-empty field of the rule matches task.
+func (r TaskRule) Matches(task *ecs.Task) bool {
+	if r.Group != "" && !matchPattern(r.Group, aws.StringValue(task.Group)) {
+		return false
+	}
+	if r.Family != "" && !matchPattern(r.Family, TaskFamily(task)) {
+		return false
+	}
+	if r.StartedBy != "" && !matchPattern(r.StartedBy, aws.StringValue(task.StartedBy)) {
+		return false
+	}
+	if r.LaunchType != "" && !strings.EqualFold(r.LaunchType, aws.StringValue(task.LaunchType)) {
+		return false
+	}
+	for key, pattern := range r.Tags {
+		value := ""
+		for _, tag := range task.Tags {
+			if aws.StringValue(tag.Key) == key {
+				value = aws.StringValue(tag.Value)
+				break
+			}
+		}
+		if !matchPattern(pattern, value) {
+			return false
+		}
+	}
+	if r.Container != "" {
+		found := false
+		for _, container := range task.Containers {
+			if matchPattern(r.Container, aws.StringValue(container.Name)) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+func (r TaskRule) validate() error {
+	patterns := []string{r.Group, r.Family, r.StartedBy, r.Container}
+	for _, pattern := range r.Tags {
+		patterns = append(patterns, pattern)
+	}
+	for _, pattern := range patterns {
+		if pattern == "" {
+			continue
+		}
+		if _, err := compilePattern(pattern); err != nil {
+			return errors.WithMessage(err, fmt.Sprintf("pattern %q", pattern))
+		}
+	}
+	return nil
+}
+
+// TaskFamily returns the family of the task's task definition.
+func TaskFamily(task *ecs.Task) string {
+	arn := aws.StringValue(task.TaskDefinitionArn)
+	family := arn[strings.LastIndex(arn, "/")+1:]
+	if i := strings.LastIndex(family, ":"); i >= 0 {
+		family = family[:i]
+	}
+	return family
+}
+
+func matchPattern(pattern, s string) bool {
+	match, err := compilePattern(pattern)
+	if err != nil {
+		// Patterns are validated when selectors are parsed
+		return false
+	}
+	return match(s)
+}
+
+func compilePattern(pattern string) (func(string) bool, error) {
+	negate := strings.HasPrefix(pattern, "!")
+	if negate {
+		pattern = pattern[1:]
+	}
+
+	var match func(string) bool
+	if len(pattern) >= 2 && strings.HasPrefix(pattern, "/") && strings.HasSuffix(pattern, "/") {
+		re, err := regexp.Compile(pattern[1 : len(pattern)-1])
+		if err != nil {
+			return nil, err
+		}
+		match = re.MatchString
+	} else {
+		re, err := globRegexp(pattern)
+		if err != nil {
+			return nil, err
+		}
+		match = re.MatchString
+	}
+
+	if negate {
+		return func(s string) bool { return !match(s) }, nil
+	}
+	return match, nil
+}
+
+// globRegexp translates a glob into a regular expression that matches the
+// whole of a string.
+func globRegexp(glob string) (*regexp.Regexp, error) {
+	var expr strings.Builder
+	expr.WriteString("(?s)^")
+	runes := []rune(glob)
+	for i := 0; i < len(runes); i++ {
+		switch r := runes[i]; r {
+		case '*':
+			expr.WriteString(".*")
+		case '?':
+			expr.WriteString(".")
+		case '\\':
+			if i++; i == len(runes) {
+				return nil, errors.New("trailing backslash in glob")
+			}
+			expr.WriteString(regexp.QuoteMeta(string(runes[i])))
+		case '[':
+			j := i + 1
+			expr.WriteString("[")
+			if j < len(runes) && (runes[j] == '!' || runes[j] == '^') {
+				expr.WriteString("^")
+				j++
+			}
+			// A "]" first in the class is part of it
+			for first := j; j < len(runes) && (runes[j] != ']' || j == first); j++ {
+				c := runes[j]
+				if c == '\\' && j+1 < len(runes) {
+					j++
+					c = runes[j]
+				} else if c == '-' {
+					expr.WriteRune(c)
+					continue
+				}
+				if !unicode.IsLetter(c) && !unicode.IsDigit(c) {
+					expr.WriteRune('\\')
+				}
+				expr.WriteRune(c)
+			}
+			if j == len(runes) {
+				return nil, errors.New("unterminated character class in glob")
+			}
+			expr.WriteString("]")
+			i = j
+		default:
+			expr.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+	expr.WriteString("$")
+	return regexp.Compile(expr.String())
+}
+
+// QuotePattern returns a pattern that matches s literally.
+func QuotePattern(s string) string {
+	var quoted strings.Builder
+	for i, r := range s {
+		if strings.ContainsRune(`\*?[]`, r) || i == 0 && (r == '!' || r == '/') {
+			quoted.WriteRune('\\')
+		}
+		quoted.WriteRune(r)
+	}
+	return quoted.String()
+}
@@ -0,0 +1,108 @@
+package internal
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ecs"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMatchPattern(t *testing.T) {
+	for _, test := range []struct {
+		pattern string
+		s       string
+		want    bool
+	}{
+		{"family:batch", "family:batch", true},
+		{"family:batch", "family:batch-1", false},
+		{"family:batch-*", "family:batch-1", true},
+		{"family:batch-*", "family:batch-1/2", true},
+		{"*", "", true},
+		{"web-?", "web-1", true},
+		{"web-?", "web-12", false},
+		{"web-[0-9]", "web-7", true},
+		{"web-[!0-9]", "web-7", false},
+		{"web-[]x]", "web-]", true},
+		{"web.1", "webx1", false},
+		{`web-\*`, "web-*", true},
+		{`web-\*`, "web-1", false},
+		{"/^cron-[0-9]+$/", "cron-42", true},
+		{"/^cron-[0-9]+$/", "cron-x", false},
+		{"!service:*", "family:batch", true},
+		{"!service:*", "service:web", false},
+		{"!/^cron-/", "ecs-svc/123", true},
+		{"!/^cron-/", "cron-1", false},
+	} {
+		assert.Equal(t, test.want, matchPattern(test.pattern, test.s), "%q matching %q", test.pattern, test.s)
+	}
+}
+
+func TestCompilePatternRejectsMalformedPatterns(t *testing.T) {
+	for _, pattern := range []string{"web-[0-9", `web-\`, "web-[9-0]", "/(/", "!/(/"} {
+		_, err := compilePattern(pattern)
+		assert.Error(t, err, pattern)
+	}
+}
+
+func TestQuotePattern(t *testing.T) {
+	for _, s := range []string{"service:web", "family:a*b", "group:[x]?", `back\slash`, "!negated", "/slashed/"} {
+		assert.True(t, matchPattern(QuotePattern(s), s), s)
+	}
+	assert.False(t, matchPattern(QuotePattern("family:a*b"), "family:axb"))
+	assert.False(t, matchPattern(QuotePattern("!negated"), "other"))
+}
+
+func TestTaskSelectorMatches(t *testing.T) {
+	task := &ecs.Task{
+		Group:             aws.String("family:batch"),
+		TaskDefinitionArn: aws.String("arn:aws:ecs:us-east-1:123456789012:task-definition/batch:3"),
+		StartedBy:         aws.String("cron-1"),
+		LaunchType:        aws.String("EC2"),
+		Tags:              []*ecs.Tag{{Key: aws.String("drain"), Value: aws.String("skip")}},
+		Containers:        []*ecs.Container{{Name: aws.String("worker")}},
+	}
+	for _, test := range []struct {
+		name     string
+		selector *TaskSelector
+		want     bool
+	}{
+		{"nil", nil, false},
+		{"no includes", &TaskSelector{}, false},
+		{"group", &TaskSelector{Include: []TaskRule{{Group: "family:*"}}}, true},
+		{"family", &TaskSelector{Include: []TaskRule{{Family: "batch"}}}, true},
+		{"launch type", &TaskSelector{Include: []TaskRule{{LaunchType: "ec2"}}}, true},
+		{"container", &TaskSelector{Include: []TaskRule{{Container: "work*"}}}, true},
+		{"every field must match", &TaskSelector{Include: []TaskRule{{Group: "family:*", StartedBy: "ecs-svc/*"}}}, false},
+		{"any include", &TaskSelector{Include: []TaskRule{{Family: "web"}, {StartedBy: "/^cron-/"}}}, true},
+		{"excluded by tag", &TaskSelector{
+			Include: []TaskRule{{Group: "family:*"}},
+			Exclude: []TaskRule{{Tags: map[string]string{"drain": "skip"}}},
+		}, false},
+		{"missing tag is empty", &TaskSelector{Include: []TaskRule{{Tags: map[string]string{"owner": "!?*"}}}}, true},
+	} {
+		assert.Equal(t, test.want, test.selector.Matches(task), test.name)
+	}
+}
+
+func TestParseTaskSelector(t *testing.T) {
+	selector, err := ParseTaskSelector("")
+	assert.NoError(t, err)
+	assert.Nil(t, selector)
+
+	selector, err = ParseTaskSelector(`{"Include": [{"Group": "family:batch-*"}], "Exclude": [{"Tags": {"drain": "skip"}}]}`)
+	assert.NoError(t, err)
+	assert.Equal(t, &TaskSelector{
+		Include: []TaskRule{{Group: "family:batch-*"}},
+		Exclude: []TaskRule{{Tags: map[string]string{"drain": "skip"}}},
+	}, selector)
+
+	for _, s := range []string{
+		`{"Include": [{"Grup": "family:batch"}]}`,
+		`{"Include": [{"Group": "family:[batch"}]}`,
+		`{"Exclude": [{"Tags": {"drain": "/(/"}}]}`,
+	} {
+		_, err := ParseTaskSelector(s)
+		assert.Error(t, err, s)
+	}
+}
@@ -0,0 +1,62 @@
+package internal
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/client"
+	"github.com/aws/aws-sdk-go/service/sfn"
+	"github.com/pkg/errors"
+)
+
+// ExecutionName returns the name of the execution started for the lifecycle
+// action.  Naming it after the action's token makes starting it idempotent,
+// as Step Functions won't start a second execution with the same name.
+func ExecutionName(event AutoScalingLifecycleEvent) string {
+	if event.LifecycleActionToken == "" {
+		return time.Now().Format("20060102T150405Z0700")
+	}
+	return event.LifecycleActionToken
+}
+
+// ExecutionExists returns true if the state machine has an execution with the
+// given name, whether or not it's still running.
+func ExecutionExists(ctx context.Context, sess client.ConfigProvider, stateMachineARN, name string) (bool, error) {
+	executionARN := strings.Replace(stateMachineARN, ":stateMachine:", ":execution:", 1) + ":" + name
+	_, err := sfn.New(sess).DescribeExecutionWithContext(
+		ctx,
+		&sfn.DescribeExecutionInput{
+			ExecutionArn: aws.String(executionARN),
+		},
+	)
+	if aerr, ok := err.(awserr.Error); ok && aerr.Code() == sfn.ErrCodeExecutionDoesNotExist {
+		return false, nil
+	}
+	if err != nil {
+		return false, errors.WithMessage(err, "DescribeExecution")
+	}
+	return true, nil
+}
+
+// StartExecution starts the named execution of the state machine.  It returns
+// false if an execution with that name already exists.
+func StartExecution(ctx context.Context, sess client.ConfigProvider, stateMachineARN, name string, input []byte) (bool, error) {
+	_, err := sfn.New(sess).StartExecutionWithContext(
+		ctx,
+		&sfn.StartExecutionInput{
+			Name:            aws.String(name),
+			StateMachineArn: aws.String(stateMachineARN),
+			Input:           aws.String(string(input)),
+		},
+	)
+	if aerr, ok := err.(awserr.Error); ok && aerr.Code() == sfn.ErrCodeExecutionAlreadyExists {
+		return false, nil
+	}
+	if err != nil {
+		return false, errors.WithMessage(err, "StartExecution")
+	}
+	return true, nil
+}
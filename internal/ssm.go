@@ -0,0 +1,96 @@
+package internal
+
+import (
+	"context"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/client"
+	"github.com/aws/aws-sdk-go/service/ssm"
+	"github.com/pkg/errors"
+)
+
+// SSMAgentPingStatus returns the ping status of the SSM agent on the EC2
+// instance, or an empty string if the agent hasn't registered yet.
+func SSMAgentPingStatus(ctx context.Context, sess client.ConfigProvider, ec2InstanceID string) (string, error) {
+	result, err := ssm.New(sess).DescribeInstanceInformationWithContext(
+		ctx,
+		&ssm.DescribeInstanceInformationInput{
+			Filters: []*ssm.InstanceInformationStringFilter{
+				{
+					Key:    aws.String(ssm.InstanceInformationFilterKeyInstanceIds),
+					Values: aws.StringSlice([]string{ec2InstanceID}),
+				},
+			},
+		},
+	)
+	if err != nil {
+		return "", errors.WithMessage(err, "DescribeInstanceInformation")
+	}
+	for _, info := range result.InstanceInformationList {
+		if aws.StringValue(info.InstanceId) == ec2InstanceID {
+			return aws.StringValue(info.PingStatus), nil
+		}
+	}
+	return "", nil
+}
+
+// DefaultTokenParameterPrefix is the SSM parameter path under which the task
+// token for an instance's readiness callback is published.
+const DefaultTokenParameterPrefix = "/ec2-lifecycle/ready-token"
+
+// TokenParameterName returns the name of the SSM parameter holding the task
+// token for the EC2 instance's readiness callback.
+func TokenParameterName(prefix, ec2InstanceID string) string {
+	return strings.TrimSuffix(prefix, "/") + "/" + ec2InstanceID
+}
+
+// PutTaskToken publishes a Step Functions task token as a SecureString
+// parameter.  Task tokens can be far longer than the 256 characters an
+// instance tag allows, so a parameter is used instead.
+func PutTaskToken(ctx context.Context, sess client.ConfigProvider, name, token string) error {
+	_, err := ssm.New(sess).PutParameterWithContext(
+		ctx,
+		&ssm.PutParameterInput{
+			Name:      aws.String(name),
+			Value:     aws.String(token),
+			Type:      aws.String(ssm.ParameterTypeSecureString),
+			Overwrite: aws.Bool(true),
+		},
+	)
+	return errors.WithMessage(err, "PutParameter")
+}
+
+// GetTaskToken returns the task token published as the named parameter, or an
+// empty string if there isn't one yet.
+func GetTaskToken(ctx context.Context, sess client.ConfigProvider, name string) (string, error) {
+	result, err := ssm.New(sess).GetParameterWithContext(
+		ctx,
+		&ssm.GetParameterInput{
+			Name:           aws.String(name),
+			WithDecryption: aws.Bool(true),
+		},
+	)
+	if err != nil {
+		if aerr, ok := err.(awserr.Error); ok && aerr.Code() == ssm.ErrCodeParameterNotFound {
+			return "", nil
+		}
+		return "", errors.WithMessage(err, "GetParameter")
+	}
+	return aws.StringValue(result.Parameter.Value), nil
+}
+
+// DeleteTaskToken deletes the named task token parameter, if it exists.
+func DeleteTaskToken(ctx context.Context, sess client.ConfigProvider, name string) error {
+	_, err := ssm.New(sess).DeleteParameterWithContext(
+		ctx,
+		&ssm.DeleteParameterInput{
+			Name: aws.String(name),
+		},
+	)
+	if aerr, ok := err.(awserr.Error); ok && aerr.Code() == ssm.ErrCodeParameterNotFound {
+		return nil
+	}
+	return errors.WithMessage(err, "DeleteParameter")
+}
@@ -3,6 +3,7 @@ package internal
 import (
 	"fmt"
 	"os"
+	"strings"
 )
 
 // MustEnv returns the value of the environment variable specified by name.
@@ -14,3 +15,44 @@ func MustEnv(name string) string {
 	}
 	return val
 }
+
+// EnvBool returns the value of the environment variable specified by name,
+// interpreted as a boolean.  Values such as "true", "yes" and "1" are true;
+// anything else is false.  If the variable is empty or not defined, def is
+// returned instead.
+func EnvBool(name string, def bool) bool {
+	switch strings.ToLower(os.Getenv(name)) {
+	case "":
+		return def
+	case "1", "true", "t", "yes", "y":
+		return true
+	}
+	return false
+}
+
+// EnvList returns the value of the environment variable specified by name,
+// split on commas.  Empty elements are omitted.
+func EnvList(name string) []string {
+	var list []string
+	for _, item := range strings.Split(os.Getenv(name), ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+// AppendMissing appends the items of more that aren't already in list.
+func AppendMissing(list, more []string) []string {
+	seen := make(map[string]bool)
+	for _, item := range list {
+		seen[item] = true
+	}
+	for _, item := range more {
+		if !seen[item] {
+			seen[item] = true
+			list = append(list, item)
+		}
+	}
+	return list
+}
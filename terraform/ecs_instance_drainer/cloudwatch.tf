@@ -24,3 +24,46 @@ resource "aws_lambda_permission" "start_drainer" {
   principal     = "events.amazonaws.com"
   source_arn    = "${aws_cloudwatch_event_rule.terminating.arn}"
 }
+
+# Standby transitions are only visible as API calls recorded by CloudTrail, so
+# a trail must be logging management events for them to be handled.
+resource "aws_cloudwatch_event_rule" "reactivating" {
+  name        = "${format("%.64s", "ecs_inst_react-${var.autoscaling_group_name}")}"
+  description = "Reactivate drained ECS instance for ${var.autoscaling_group_name}"
+
+  event_pattern = <<PATTERN
+{
+    "$or": [
+        {
+            "detail-type": [ "EC2 Instance Launch Successful" ],
+            "detail": {
+                "AutoScalingGroupName": [ "${var.autoscaling_group_name}" ]
+            }
+        },
+        {
+            "detail-type": [ "AWS API Call via CloudTrail" ],
+            "detail": {
+                "eventSource": [ "autoscaling.amazonaws.com" ],
+                "eventName": [ "EnterStandby", "ExitStandby" ],
+                "requestParameters": {
+                    "autoScalingGroupName": [ "${var.autoscaling_group_name}" ]
+                }
+            }
+        }
+    ]
+}
+PATTERN
+}
+
+resource "aws_cloudwatch_event_target" "reactivating" {
+  rule = "${aws_cloudwatch_event_rule.reactivating.name}"
+  arn  = "${aws_lambda_function.reactivate_instance.arn}"
+}
+
+resource "aws_lambda_permission" "reactivate_instance" {
+  statement_id  = "AllowExecutionFromCloudWatch"
+  action        = "lambda:InvokeFunction"
+  function_name = "${aws_lambda_function.reactivate_instance.function_name}"
+  principal     = "events.amazonaws.com"
+  source_arn    = "${aws_cloudwatch_event_rule.reactivating.arn}"
+}
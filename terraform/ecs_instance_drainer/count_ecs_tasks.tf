@@ -32,7 +32,19 @@ data "aws_iam_policy_document" "count_ecs_tasks_policy" {
   }
 
   statement {
-    actions   = ["ecs:ListTasks"]
+    actions = [
+      "ecs:ListTasks",
+      "ecs:DescribeTasks",
+      "ecs:GetTaskProtection",
+      "ecs:DescribeServices",
+      "ecs:DescribeContainerInstances",
+    ]
+
+    resources = ["*"]
+  }
+
+  statement {
+    actions   = ["elasticloadbalancing:DescribeTargetHealth"]
     resources = ["*"]
   }
 }
@@ -0,0 +1,49 @@
+resource "aws_lambda_function" "deregister_instance" {
+  function_name = "${format("%.64s", "ecs-inst-drain-dereg-${var.autoscaling_group_name}")}"
+  description   = "ECS instance drainer - deregister-container-instance for ${var.autoscaling_group_name} Auto Scaling Group"
+  role          = "${aws_iam_role.deregister_instance.arn}"
+
+  s3_bucket = "${var.s3_bucket}"
+  s3_key    = "${var.lambda_version}/deregister-container-instance.zip"
+  handler   = "deregister-container-instance"
+  runtime   = "go1.x"
+}
+
+data "aws_iam_policy_document" "deregister_instance_assume_role" {
+  statement {
+    actions = ["sts:AssumeRole"]
+
+    principals {
+      type        = "Service"
+      identifiers = ["lambda.amazonaws.com"]
+    }
+  }
+}
+
+data "aws_iam_policy_document" "deregister_instance_policy" {
+  statement {
+    actions = [
+      "logs:CreateLogGroup",
+      "logs:CreateLogStream",
+      "logs:PutLogEvents",
+    ]
+
+    resources = ["*"]
+  }
+
+  statement {
+    actions   = ["ecs:DeregisterContainerInstance"]
+    resources = ["*"]
+  }
+}
+
+resource "aws_iam_role" "deregister_instance" {
+  name               = "${format("%.64s", "ecs-inst-drain-dereg-${var.autoscaling_group_name}")}"
+  assume_role_policy = "${data.aws_iam_policy_document.deregister_instance_assume_role.json}"
+}
+
+resource "aws_iam_role_policy" "deregister_instance" {
+  name   = "deregister_instance"
+  role   = "${aws_iam_role.deregister_instance.name}"
+  policy = "${data.aws_iam_policy_document.deregister_instance_policy.json}"
+}
@@ -0,0 +1,72 @@
+resource "aws_lambda_function" "drain_instance" {
+  function_name = "${format("%.64s", "ecs-inst-drain-inst-${var.autoscaling_group_name}")}"
+  description   = "ECS instance drainer - drain-ecs-instance for ${var.autoscaling_group_name} Auto Scaling Group"
+  role          = "${aws_iam_role.drain_instance.arn}"
+
+  s3_bucket = "${var.s3_bucket}"
+  s3_key    = "${var.lambda_version}/drain-ecs-instance.zip"
+  handler   = "drain-ecs-instance"
+  runtime   = "go1.x"
+}
+
+data "aws_iam_policy_document" "drain_instance_assume_role" {
+  statement {
+    actions = ["sts:AssumeRole"]
+
+    principals {
+      type        = "Service"
+      identifiers = ["lambda.amazonaws.com"]
+    }
+  }
+}
+
+data "aws_iam_policy_document" "drain_instance_policy" {
+  statement {
+    actions = [
+      "logs:CreateLogGroup",
+      "logs:CreateLogStream",
+      "logs:PutLogEvents",
+    ]
+
+    resources = ["*"]
+  }
+
+  statement {
+    actions = [
+      "ecs:UpdateContainerInstancesState",
+      "ecs:ListContainerInstances",
+      "ecs:DescribeContainerInstances",
+      "ecs:ListTasks",
+      "ecs:DescribeTasks",
+      "ecs:GetTaskProtection",
+      "ecs:DescribeTaskDefinition",
+      "ecs:DescribeServices",
+      "ecs:StopTask",
+      "ecs:UpdateService",
+      "ecs:PutAttributes",
+    ]
+
+    resources = ["*"]
+  }
+
+  statement {
+    actions   = ["autoscaling:DescribeAutoScalingGroups"]
+    resources = ["*"]
+  }
+
+  statement {
+    actions   = ["autoscaling:SetDesiredCapacity"]
+    resources = ["arn:aws:autoscaling:*:*:autoScalingGroup:*:autoScalingGroupName/${var.autoscaling_group_name}"]
+  }
+}
+
+resource "aws_iam_role" "drain_instance" {
+  name               = "${format("%.64s", "ecs-inst-drain-inst-${var.autoscaling_group_name}")}"
+  assume_role_policy = "${data.aws_iam_policy_document.drain_instance_assume_role.json}"
+}
+
+resource "aws_iam_role_policy" "drain_instance" {
+  name   = "drain_instance"
+  role   = "${aws_iam_role.drain_instance.name}"
+  policy = "${data.aws_iam_policy_document.drain_instance_policy.json}"
+}
@@ -0,0 +1,63 @@
+resource "aws_lambda_function" "reactivate_instance" {
+  function_name = "${format("%.64s", "ecs-inst-drain-react-${var.autoscaling_group_name}")}"
+  description   = "ECS instance drainer - reactivate-ecs-instance for ${var.autoscaling_group_name} Auto Scaling Group"
+  role          = "${aws_iam_role.reactivate_instance.arn}"
+
+  s3_bucket = "${var.s3_bucket}"
+  s3_key    = "${var.lambda_version}/reactivate-ecs-instance.zip"
+  handler   = "reactivate-ecs-instance"
+  runtime   = "go1.x"
+
+  environment {
+    variables = {
+      ECS_CLUSTER = "${join(",", coalescelist(var.ecs_cluster_names, list(coalesce(var.ecs_cluster_name, var.autoscaling_group_name))))}"
+    }
+  }
+}
+
+data "aws_iam_policy_document" "reactivate_instance_assume_role" {
+  statement {
+    actions = ["sts:AssumeRole"]
+
+    principals {
+      type        = "Service"
+      identifiers = ["lambda.amazonaws.com"]
+    }
+  }
+}
+
+data "aws_iam_policy_document" "reactivate_instance_policy" {
+  statement {
+    actions = [
+      "logs:CreateLogGroup",
+      "logs:CreateLogStream",
+      "logs:PutLogEvents",
+    ]
+
+    resources = ["*"]
+  }
+
+  statement {
+    actions = [
+      "ecs:ListClusters",
+      "ecs:ListContainerInstances",
+      "ecs:DescribeContainerInstances",
+      "ecs:UpdateContainerInstancesState",
+      "ecs:PutAttributes",
+      "ecs:DeleteAttributes",
+    ]
+
+    resources = ["*"]
+  }
+}
+
+resource "aws_iam_role" "reactivate_instance" {
+  name               = "${format("%.64s", "ecs-inst-drain-react-${var.autoscaling_group_name}")}"
+  assume_role_policy = "${data.aws_iam_policy_document.reactivate_instance_assume_role.json}"
+}
+
+resource "aws_iam_role_policy" "reactivate_instance" {
+  name   = "reactivate_instance"
+  role   = "${aws_iam_role.reactivate_instance.name}"
+  policy = "${data.aws_iam_policy_document.reactivate_instance_policy.json}"
+}
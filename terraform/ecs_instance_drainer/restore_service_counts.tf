@@ -0,0 +1,49 @@
+resource "aws_lambda_function" "restore_service_counts" {
+  function_name = "${format("%.64s", "ecs-inst-drain-restore-${var.autoscaling_group_name}")}"
+  description   = "ECS instance drainer - restore-service-counts for ${var.autoscaling_group_name} Auto Scaling Group"
+  role          = "${aws_iam_role.restore_service_counts.arn}"
+
+  s3_bucket = "${var.s3_bucket}"
+  s3_key    = "${var.lambda_version}/restore-service-counts.zip"
+  handler   = "restore-service-counts"
+  runtime   = "go1.x"
+}
+
+data "aws_iam_policy_document" "restore_service_counts_assume_role" {
+  statement {
+    actions = ["sts:AssumeRole"]
+
+    principals {
+      type        = "Service"
+      identifiers = ["lambda.amazonaws.com"]
+    }
+  }
+}
+
+data "aws_iam_policy_document" "restore_service_counts_policy" {
+  statement {
+    actions = [
+      "logs:CreateLogGroup",
+      "logs:CreateLogStream",
+      "logs:PutLogEvents",
+    ]
+
+    resources = ["*"]
+  }
+
+  statement {
+    actions   = ["ecs:UpdateService"]
+    resources = ["*"]
+  }
+}
+
+resource "aws_iam_role" "restore_service_counts" {
+  name               = "${format("%.64s", "ecs-inst-drain-restore-${var.autoscaling_group_name}")}"
+  assume_role_policy = "${data.aws_iam_policy_document.restore_service_counts_assume_role.json}"
+}
+
+resource "aws_iam_role_policy" "restore_service_counts" {
+  name   = "restore_service_counts"
+  role   = "${aws_iam_role.restore_service_counts.name}"
+  policy = "${data.aws_iam_policy_document.restore_service_counts_policy.json}"
+}
@@ -0,0 +1,53 @@
+resource "aws_lambda_function" "run_drain_command" {
+  function_name = "${format("%.64s", "ecs-inst-drain-cmd-${var.autoscaling_group_name}")}"
+  description   = "ECS instance drainer - run-drain-command for ${var.autoscaling_group_name} Auto Scaling Group"
+  role          = "${aws_iam_role.run_drain_command.arn}"
+
+  s3_bucket = "${var.s3_bucket}"
+  s3_key    = "${var.lambda_version}/run-drain-command.zip"
+  handler   = "run-drain-command"
+  runtime   = "go1.x"
+}
+
+data "aws_iam_policy_document" "run_drain_command_assume_role" {
+  statement {
+    actions = ["sts:AssumeRole"]
+
+    principals {
+      type        = "Service"
+      identifiers = ["lambda.amazonaws.com"]
+    }
+  }
+}
+
+data "aws_iam_policy_document" "run_drain_command_policy" {
+  statement {
+    actions = [
+      "logs:CreateLogGroup",
+      "logs:CreateLogStream",
+      "logs:PutLogEvents",
+    ]
+
+    resources = ["*"]
+  }
+
+  statement {
+    actions = [
+      "ssm:SendCommand",
+      "ssm:GetCommandInvocation",
+    ]
+
+    resources = ["*"]
+  }
+}
+
+resource "aws_iam_role" "run_drain_command" {
+  name               = "${format("%.64s", "ecs-inst-drain-cmd-${var.autoscaling_group_name}")}"
+  assume_role_policy = "${data.aws_iam_policy_document.run_drain_command_assume_role.json}"
+}
+
+resource "aws_iam_role_policy" "run_drain_command" {
+  name   = "run_drain_command"
+  role   = "${aws_iam_role.run_drain_command.name}"
+  policy = "${data.aws_iam_policy_document.run_drain_command_policy.json}"
+}
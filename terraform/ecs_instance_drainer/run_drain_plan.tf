@@ -0,0 +1,55 @@
+resource "aws_lambda_function" "run_drain_plan" {
+  function_name = "${format("%.64s", "ecs-inst-drain-run-plan-${var.autoscaling_group_name}")}"
+  description   = "ECS instance drainer - run-drain-plan for ${var.autoscaling_group_name} Auto Scaling Group"
+  role          = "${aws_iam_role.run_drain_plan.arn}"
+
+  s3_bucket = "${var.s3_bucket}"
+  s3_key    = "${var.lambda_version}/run-drain-plan.zip"
+  handler   = "run-drain-plan"
+  runtime   = "go1.x"
+}
+
+data "aws_iam_policy_document" "run_drain_plan_assume_role" {
+  statement {
+    actions = ["sts:AssumeRole"]
+
+    principals {
+      type        = "Service"
+      identifiers = ["lambda.amazonaws.com"]
+    }
+  }
+}
+
+data "aws_iam_policy_document" "run_drain_plan_policy" {
+  statement {
+    actions = [
+      "logs:CreateLogGroup",
+      "logs:CreateLogStream",
+      "logs:PutLogEvents",
+    ]
+
+    resources = ["*"]
+  }
+
+  statement {
+    actions = [
+      "ecs:ListTasks",
+      "ecs:DescribeTasks",
+      "ecs:GetTaskProtection",
+      "ecs:StopTask",
+    ]
+
+    resources = ["*"]
+  }
+}
+
+resource "aws_iam_role" "run_drain_plan" {
+  name               = "${format("%.64s", "ecs-inst-drain-run-plan-${var.autoscaling_group_name}")}"
+  assume_role_policy = "${data.aws_iam_policy_document.run_drain_plan_assume_role.json}"
+}
+
+resource "aws_iam_role_policy" "run_drain_plan" {
+  name   = "run_drain_plan"
+  role   = "${aws_iam_role.run_drain_plan.name}"
+  policy = "${data.aws_iam_policy_document.run_drain_plan_policy.json}"
+}
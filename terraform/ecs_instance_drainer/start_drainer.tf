@@ -10,11 +10,25 @@ resource "aws_lambda_function" "start_drainer" {
 
   environment {
     variables = {
-      STATE_MACHINE_ARN          = "${aws_sfn_state_machine.drainer.id}"
-      ECS_CLUSTER                = "${coalesce(var.ecs_cluster_name, var.autoscaling_group_name)}"
-      TIMEOUT                    = "${var.timeout}"
-      STOP_ALL_NON_SERVICE_TASKS = "${var.stop_all_non_service_tasks}"
-      STOP_TASK_GROUPS           = "${join(",", var.stop_task_groups)}"
+      STATE_MACHINE_ARN             = "${aws_sfn_state_machine.drainer.id}"
+      ECS_CLUSTER                   = "${join(",", coalescelist(var.ecs_cluster_names, list(coalesce(var.ecs_cluster_name, var.autoscaling_group_name))))}"
+      TIMEOUT                       = "${var.timeout}"
+      STOP_ALL_NON_SERVICE_TASKS    = "${var.stop_all_non_service_tasks}"
+      STOP_TASK_GROUPS              = "${join(",", var.stop_task_groups)}"
+      STOP_TASK_SELECTOR            = "${var.stop_task_selector}"
+      STOP_TASK_CONCURRENCY         = "${var.stop_task_concurrency}"
+      COUNT_TASK_SELECTOR           = "${var.count_task_selector}"
+      DRAIN_PLAN                    = "${var.drain_plan}"
+      IGNORE_DAEMON_SERVICE_TASKS   = "${var.ignore_daemon_service_tasks}"
+      IGNORED_TASK_FAMILIES         = "${join(",", var.ignored_task_families)}"
+      WAIT_FOR_SERVICE_REPLACEMENTS = "${var.wait_for_service_replacements}"
+      CHECK_CAPACITY                = "${var.check_capacity}"
+      SCALE_OUT_FOR_CAPACITY        = "${var.scale_out_for_capacity}"
+      OVERPROVISION_SERVICES        = "${var.overprovision_services}"
+      DEREGISTER_CONTAINER_INSTANCE = "${var.deregister_container_instance}"
+      FORCE_DEREGISTER              = "${var.force_deregister}"
+      WAIT_FOR_LIFECYCLE_AGENT      = "${var.wait_for_lifecycle_agent}"
+      DRAIN_COMMAND                 = "${var.drain_command}"
     }
   }
 }
@@ -47,7 +61,11 @@ data "aws_iam_policy_document" "start_drainer_policy" {
       "ecs:DescribeContainerInstances",
       "ecs:ListTasks",
       "ecs:DescribeTasks",
+      "ecs:GetTaskProtection",
+      "ecs:DescribeServices",
+      "ecs:DescribeTaskDefinition",
       "ecs:StopTask",
+      "ecs:PutAttributes",
     ]
 
     resources = ["*"]
@@ -56,15 +74,31 @@ data "aws_iam_policy_document" "start_drainer_policy" {
   statement {
     actions = [
       "ecs:ListContainerInstances",
+      "ecs:ListClusters",
     ]
 
     resources = ["*"]
   }
 
+  statement {
+    actions   = ["autoscaling:DescribeAutoScalingGroups"]
+    resources = ["*"]
+  }
+
+  statement {
+    actions   = ["autoscaling:SetDesiredCapacity"]
+    resources = ["arn:aws:autoscaling:*:*:autoScalingGroup:*:autoScalingGroupName/${var.autoscaling_group_name}"]
+  }
+
   statement {
     actions   = ["states:StartExecution"]
     resources = ["${aws_sfn_state_machine.drainer.id}"]
   }
+
+  statement {
+    actions   = ["states:DescribeExecution"]
+    resources = ["${replace(aws_sfn_state_machine.drainer.id, ":stateMachine:", ":execution:")}:*"]
+  }
 }
 
 resource "aws_iam_role" "start_drainer" {
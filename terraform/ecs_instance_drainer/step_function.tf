@@ -10,6 +10,26 @@ resource "aws_sfn_state_machine" "drainer" {
         "CountRunningExecutions": {
             "Type": "Task",
             "Resource": "${aws_lambda_function.count_running_executions.arn}",
+            "Retry": [
+                {
+                    "ErrorEquals": [
+                        "Lambda.ServiceException",
+                        "Lambda.AWSLambdaException",
+                        "Lambda.SdkClientException",
+                        "Lambda.TooManyRequestsException"
+                    ],
+                    "IntervalSeconds": 2,
+                    "MaxAttempts": 6,
+                    "BackoffRate": 2
+                }
+            ],
+            "Catch": [
+                {
+                    "ErrorEquals": ["States.ALL"],
+                    "ResultPath": "$.StepError",
+                    "Next": "AbandonLifecycleAction"
+                }
+            ],
             "Next": "HaltIfRunningExecutions"
         },
         "HaltIfRunningExecutions": {
@@ -21,7 +41,7 @@ resource "aws_sfn_state_machine" "drainer" {
                     "Next": "AlreadyRunning"
                 }
             ],
-            "Default": "CountRunningTasks"
+            "Default": "RunDrainCommand"
         },
         "AlreadyRunning": {
             "Type": "Fail",
@@ -30,6 +50,26 @@ resource "aws_sfn_state_machine" "drainer" {
         "CheckDeadline": {
             "Type": "Task",
             "Resource": "${aws_lambda_function.check_deadline.arn}",
+            "Retry": [
+                {
+                    "ErrorEquals": [
+                        "Lambda.ServiceException",
+                        "Lambda.AWSLambdaException",
+                        "Lambda.SdkClientException",
+                        "Lambda.TooManyRequestsException"
+                    ],
+                    "IntervalSeconds": 2,
+                    "MaxAttempts": 6,
+                    "BackoffRate": 2
+                }
+            ],
+            "Catch": [
+                {
+                    "ErrorEquals": ["States.ALL"],
+                    "ResultPath": "$.StepError",
+                    "Next": "AbandonLifecycleAction"
+                }
+            ],
             "Next": "HaltIfPastDeadline"
         },
         "HaltIfPastDeadline": {
@@ -41,27 +81,262 @@ resource "aws_sfn_state_machine" "drainer" {
                     "Next": "AbandonLifecycleAction"
                 }
             ],
-            "Default": "CountRunningTasks"
+            "Default": "RunDrainCommand"
+        },
+        "RunDrainCommand": {
+            "Type": "Task",
+            "Resource": "${aws_lambda_function.run_drain_command.arn}",
+            "Retry": [
+                {
+                    "ErrorEquals": [
+                        "Lambda.ServiceException",
+                        "Lambda.AWSLambdaException",
+                        "Lambda.SdkClientException",
+                        "Lambda.TooManyRequestsException"
+                    ],
+                    "IntervalSeconds": 2,
+                    "MaxAttempts": 6,
+                    "BackoffRate": 2
+                }
+            ],
+            "Catch": [
+                {
+                    "ErrorEquals": ["States.ALL"],
+                    "ResultPath": "$.StepError",
+                    "Next": "AbandonLifecycleAction"
+                }
+            ],
+            "Next": "DrainUnlessCommandRunning"
+        },
+        "DrainUnlessCommandRunning": {
+            "Type": "Choice",
+            "Choices": [
+                {
+                    "Variable": "$.DrainCommandDone",
+                    "BooleanEquals": true,
+                    "Next": "CheckDraining"
+                }
+            ],
+            "Default": "Heartbeat"
+        },
+        "CheckDraining": {
+            "Type": "Choice",
+            "Choices": [
+                {
+                    "And": [
+                        {
+                            "Variable": "$.Draining",
+                            "BooleanEquals": true
+                        },
+                        {
+                            "Variable": "$.ProtectedTasksPending",
+                            "BooleanEquals": false
+                        }
+                    ],
+                    "Next": "RunDrainPlan"
+                }
+            ],
+            "Default": "DrainInstance"
+        },
+        "DrainInstance": {
+            "Type": "Task",
+            "Resource": "${aws_lambda_function.drain_instance.arn}",
+            "Retry": [
+                {
+                    "ErrorEquals": [
+                        "Lambda.ServiceException",
+                        "Lambda.AWSLambdaException",
+                        "Lambda.SdkClientException",
+                        "Lambda.TooManyRequestsException"
+                    ],
+                    "IntervalSeconds": 2,
+                    "MaxAttempts": 6,
+                    "BackoffRate": 2
+                }
+            ],
+            "Catch": [
+                {
+                    "ErrorEquals": ["States.ALL"],
+                    "ResultPath": "$.DrainError",
+                    "Next": "AbandonLifecycleAction"
+                }
+            ],
+            "Next": "WaitUnlessDraining"
+        },
+        "WaitUnlessDraining": {
+            "Type": "Choice",
+            "Choices": [
+                {
+                    "Variable": "$.Draining",
+                    "BooleanEquals": true,
+                    "Next": "RunDrainPlan"
+                }
+            ],
+            "Default": "Heartbeat"
+        },
+        "RunDrainPlan": {
+            "Type": "Task",
+            "Resource": "${aws_lambda_function.run_drain_plan.arn}",
+            "Retry": [
+                {
+                    "ErrorEquals": [
+                        "Lambda.ServiceException",
+                        "Lambda.AWSLambdaException",
+                        "Lambda.SdkClientException",
+                        "Lambda.TooManyRequestsException"
+                    ],
+                    "IntervalSeconds": 2,
+                    "MaxAttempts": 6,
+                    "BackoffRate": 2
+                }
+            ],
+            "Catch": [
+                {
+                    "ErrorEquals": ["States.ALL"],
+                    "ResultPath": "$.StepError",
+                    "Next": "AbandonLifecycleAction"
+                }
+            ],
+            "Next": "CountRunningTasks"
         },
         "CountRunningTasks": {
             "Type": "Task",
             "Resource": "${aws_lambda_function.count_ecs_tasks.arn}",
-            "Next": "CompleteIfNoTasks" 
+            "Retry": [
+                {
+                    "ErrorEquals": [
+                        "Lambda.ServiceException",
+                        "Lambda.AWSLambdaException",
+                        "Lambda.SdkClientException",
+                        "Lambda.TooManyRequestsException"
+                    ],
+                    "IntervalSeconds": 2,
+                    "MaxAttempts": 6,
+                    "BackoffRate": 2
+                }
+            ],
+            "Catch": [
+                {
+                    "ErrorEquals": ["States.ALL"],
+                    "ResultPath": "$.StepError",
+                    "Next": "AbandonLifecycleAction"
+                }
+            ],
+            "Next": "CompleteIfNoTasks"
         },
         "CompleteIfNoTasks": {
             "Type": "Choice",
             "Choices": [
                 {
-                    "Variable": "$.ECSTaskCount",
-                    "NumericEquals": 0,
-                    "Next": "ContinueLifecycleAction"
+                    "And": [
+                        {
+                            "Variable": "$.ECSTaskCount",
+                            "NumericEquals": 0
+                        },
+                        {
+                            "Variable": "$.Partial",
+                            "BooleanEquals": false
+                        },
+                        {
+                            "Variable": "$.ServicesSettled",
+                            "BooleanEquals": true
+                        }
+                    ],
+                    "Next": "CheckLifecycleAgent"
+                }
+            ],
+            "Default": "Heartbeat"
+        },
+        "CheckLifecycleAgent": {
+            "Type": "Task",
+            "Resource": "${aws_lambda_function.check_lifecycle_agent.arn}",
+            "ResultPath": "$.Agent",
+            "Retry": [
+                {
+                    "ErrorEquals": [
+                        "Lambda.ServiceException",
+                        "Lambda.AWSLambdaException",
+                        "Lambda.SdkClientException",
+                        "Lambda.TooManyRequestsException"
+                    ],
+                    "IntervalSeconds": 2,
+                    "MaxAttempts": 6,
+                    "BackoffRate": 2
+                }
+            ],
+            "Catch": [
+                {
+                    "ErrorEquals": ["States.ALL"],
+                    "ResultPath": "$.StepError",
+                    "Next": "AbandonLifecycleAction"
+                }
+            ],
+            "Next": "CompleteIfAgentFinished"
+        },
+        "CompleteIfAgentFinished": {
+            "Type": "Choice",
+            "Choices": [
+                {
+                    "Variable": "$.Agent.Status",
+                    "StringEquals": "failed",
+                    "Next": "AbandonLifecycleAction"
+                },
+                {
+                    "Variable": "$.Agent.Finished",
+                    "BooleanEquals": true,
+                    "Next": "DeregisterContainerInstance"
                 }
             ],
             "Default": "Heartbeat"
         },
+        "DeregisterContainerInstance": {
+            "Type": "Task",
+            "Resource": "${aws_lambda_function.deregister_instance.arn}",
+            "Retry": [
+                {
+                    "ErrorEquals": [
+                        "Lambda.ServiceException",
+                        "Lambda.AWSLambdaException",
+                        "Lambda.SdkClientException",
+                        "Lambda.TooManyRequestsException"
+                    ],
+                    "IntervalSeconds": 2,
+                    "MaxAttempts": 6,
+                    "BackoffRate": 2
+                }
+            ],
+            "Catch": [
+                {
+                    "ErrorEquals": ["States.ALL"],
+                    "ResultPath": "$.StepError",
+                    "Next": "AbandonLifecycleAction"
+                }
+            ],
+            "Next": "ContinueLifecycleAction"
+        },
         "Heartbeat": {
             "Type": "Task",
             "Resource": "${aws_lambda_function.record_lifecycle_heartbeat.arn}",
+            "Retry": [
+                {
+                    "ErrorEquals": [
+                        "Lambda.ServiceException",
+                        "Lambda.AWSLambdaException",
+                        "Lambda.SdkClientException",
+                        "Lambda.TooManyRequestsException"
+                    ],
+                    "IntervalSeconds": 2,
+                    "MaxAttempts": 6,
+                    "BackoffRate": 2
+                }
+            ],
+            "Catch": [
+                {
+                    "ErrorEquals": ["States.ALL"],
+                    "ResultPath": "$.StepError",
+                    "Next": "AbandonLifecycleAction"
+                }
+            ],
             "Next": "WaitAndCountAgain"
         },
         "WaitAndCountAgain": {
@@ -75,7 +350,7 @@ resource "aws_sfn_state_machine" "drainer" {
                 "LifecycleActionResult": "CONTINUE"
             },
             "ResultPath": "$.Params",
-            "Next": "CompleteLifecycleAction"
+            "Next": "RestoreServiceCounts"
         },
         "AbandonLifecycleAction": {
             "Type": "Pass",
@@ -83,11 +358,44 @@ resource "aws_sfn_state_machine" "drainer" {
                 "LifecycleActionResult": "ABANDON"
             },
             "ResultPath": "$.Params",
+            "Next": "RestoreServiceCounts"
+        },
+        "RestoreServiceCounts": {
+            "Type": "Task",
+            "Resource": "${aws_lambda_function.restore_service_counts.arn}",
+            "Retry": [
+                {
+                    "ErrorEquals": ["States.ALL"],
+                    "IntervalSeconds": 5,
+                    "MaxAttempts": 3,
+                    "BackoffRate": 2
+                }
+            ],
+            "Catch": [
+                {
+                    "ErrorEquals": ["States.ALL"],
+                    "ResultPath": "$.RestoreError",
+                    "Next": "CompleteLifecycleAction"
+                }
+            ],
             "Next": "CompleteLifecycleAction"
         },
         "CompleteLifecycleAction": {
             "Type": "Task",
             "Resource": "${aws_lambda_function.complete_lifecycle_action.arn}",
+            "Retry": [
+                {
+                    "ErrorEquals": [
+                        "Lambda.ServiceException",
+                        "Lambda.AWSLambdaException",
+                        "Lambda.SdkClientException",
+                        "Lambda.TooManyRequestsException"
+                    ],
+                    "IntervalSeconds": 2,
+                    "MaxAttempts": 6,
+                    "BackoffRate": 2
+                }
+            ],
             "End": true
         }
     }
@@ -114,6 +422,12 @@ data "aws_iam_policy_document" "drainer_policy" {
       "${aws_lambda_function.count_running_executions.arn}",
       "${aws_lambda_function.check_deadline.arn}",
       "${aws_lambda_function.count_ecs_tasks.arn}",
+      "${aws_lambda_function.run_drain_plan.arn}",
+      "${aws_lambda_function.drain_instance.arn}",
+      "${aws_lambda_function.run_drain_command.arn}",
+      "${aws_lambda_function.restore_service_counts.arn}",
+      "${aws_lambda_function.deregister_instance.arn}",
+      "${aws_lambda_function.check_lifecycle_agent.arn}",
       "${aws_lambda_function.complete_lifecycle_action.arn}",
       "${aws_lambda_function.record_lifecycle_heartbeat.arn}",
     ]
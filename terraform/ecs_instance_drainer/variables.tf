@@ -8,6 +8,11 @@ variable "ecs_cluster_name" {
   default     = ""
 }
 
+variable "ecs_cluster_names" {
+  description = "ECS clusters to search for the instance, if it may belong to any of several.  Use [\"*\"] to search every cluster in the region.  Overrides ecs_cluster_name."
+  default     = []
+}
+
 variable "wait_interval" {
   description = "Number of seconds to wait between counting ECS tasks"
   default     = "30"
@@ -28,6 +33,76 @@ variable "stop_task_groups" {
   default     = []
 }
 
+variable "stop_task_selector" {
+  description = "JSON task selector choosing additional ECS tasks to stop immediately, e.g. {\"Include\": [{\"Family\": \"batch-*\"}]}"
+  default     = ""
+}
+
+variable "count_task_selector" {
+  description = "JSON task selector choosing which ECS tasks must stop before the drain completes.  If blank, all tasks are counted."
+  default     = ""
+}
+
+variable "drain_plan" {
+  description = "JSON list of drain stages, each with a Name, task Selector, and optional Delay and Timeout as Go duration strings.  Stages run in order across poll iterations."
+  default     = ""
+}
+
+variable "ignore_daemon_service_tasks" {
+  description = "If true, tasks belonging to DAEMON services don't hold up the drain"
+  default     = "false"
+}
+
+variable "ignored_task_families" {
+  description = "List of ECS task families whose tasks don't hold up the drain"
+  default     = []
+}
+
+variable "wait_for_service_replacements" {
+  description = "If true, wait for services that had tasks on the instance to reach their desired count, finish deploying, and pass target group health checks before completing the drain"
+  default     = "false"
+}
+
+variable "check_capacity" {
+  description = "If true, don't drain the instance until the rest of the cluster has enough CPU, memory and ports for its service tasks"
+  default     = "false"
+}
+
+variable "scale_out_for_capacity" {
+  description = "If true and check_capacity is set, add an instance to the Auto Scaling Group when the cluster lacks capacity"
+  default     = "false"
+}
+
+variable "overprovision_services" {
+  description = "If true, raise the desired count of services with tasks on the instance until their extra tasks are running elsewhere, then drain it; the original counts are restored when the drain is over"
+  default     = "false"
+}
+
+variable "deregister_container_instance" {
+  description = "If true, deregister the container instance from the ECS cluster once it has been drained"
+  default     = "false"
+}
+
+variable "force_deregister" {
+  description = "If true, deregister the container instance even if tasks are still running on it"
+  default     = "false"
+}
+
+variable "stop_task_concurrency" {
+  description = "Maximum number of ECS tasks to stop concurrently"
+  default     = "10"
+}
+
+variable "wait_for_lifecycle_agent" {
+  description = "If true, the drain isn't complete until lifecycle-agent on the instance has run its Terminated hooks; if they fail, the lifecycle action is abandoned"
+  default     = "false"
+}
+
+variable "drain_command" {
+  description = "JSON spec of a command run on the instance with SSM Run Command before it's drained, e.g. {\"Commands\": [\"systemctl stop app\"]}.  The drain goes ahead even if the command fails, or hasn't finished with half the time left before the heartbeat deadline gone.  If blank, no command is run."
+  default     = ""
+}
+
 variable "lambda_version" {
   type        = "string"
   description = "Lambda function version"
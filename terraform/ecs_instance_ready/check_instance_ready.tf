@@ -35,7 +35,22 @@ data "aws_iam_policy_document" "check_instance_ready_policy" {
     actions = [
       "ecs:DescribeContainerInstances",
       "ecs:ListContainerInstances",
+      "ecs:ListClusters",
       "ecs:ListTasks",
+      "ecs:DescribeTasks",
+      "ecs:UpdateContainerInstancesState",
+      "ecs:PutAttributes",
+      "ecs:DeleteAttributes",
+    ]
+
+    resources = ["*"]
+  }
+
+  # CHECK_BASELINE
+  statement {
+    actions = [
+      "ec2:DescribeInstanceStatus",
+      "ssm:DescribeInstanceInformation",
     ]
 
     resources = ["*"]
@@ -11,9 +11,12 @@ resource "aws_lambda_function" "start_poller" {
   environment {
     variables = {
       STATE_MACHINE_ARN      = "${aws_sfn_state_machine.poller.id}"
-      ECS_CLUSTER            = "${coalesce(var.ecs_cluster_name, var.autoscaling_group_name)}"
+      ECS_CLUSTER            = "${join(",", coalescelist(var.ecs_cluster_names, list(coalesce(var.ecs_cluster_name, var.autoscaling_group_name))))}"
       TIMEOUT                = "${var.timeout}"
       REQUIRED_TASK_FAMILIES = "${join(",", var.required_task_families)}"
+      READINESS_SPEC         = "${var.readiness_spec}"
+      QUARANTINE             = "${var.quarantine}"
+      CHECK_BASELINE         = "${var.check_baseline}"
     }
   }
 }
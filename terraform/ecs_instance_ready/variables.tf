@@ -8,6 +8,11 @@ variable "ecs_cluster_name" {
   default     = ""
 }
 
+variable "ecs_cluster_names" {
+  description = "ECS clusters to search for the instance, if it may belong to any of several.  Use [\"*\"] to search every cluster in the region.  Overrides ecs_cluster_name."
+  default     = []
+}
+
 variable "wait_interval" {
   description = "Number of seconds to wait between poll attempts"
   default     = "30"
@@ -23,6 +28,21 @@ variable "required_task_families" {
   default     = []
 }
 
+variable "readiness_spec" {
+  description = "JSON readiness spec listing minimum counts of RUNNING tasks per task family or service, optionally HEALTHY, and DAEMON services that must have a task on the instance, and host criteria such as minimum agent and Docker versions, attributes and registered resources"
+  default     = ""
+}
+
+variable "quarantine" {
+  description = "How to keep tasks off the instance until it's ready: \"DRAINING\" sets it to DRAINING, which also keeps DAEMON service tasks off it, so it can't be combined with required tasks or daemon services; \"ATTRIBUTE\" sets the ecs-instance-ready.quarantined attribute, which services must avoid with a placement constraint.  If blank, the instance isn't quarantined."
+  default     = ""
+}
+
+variable "check_baseline" {
+  description = "If true, the instance must pass its EC2 status checks and have its SSM agent online before the ECS instance is checked"
+  default     = "false"
+}
+
 variable "lambda_version" {
   type        = "string"
   description = "Lambda function version"
@@ -35,6 +35,16 @@ data "aws_iam_policy_document" "check_kafka_ready_policy" {
 
     resources = ["*"]
   }
+
+  # CHECK_BASELINE
+  statement {
+    actions = [
+      "ec2:DescribeInstanceStatus",
+      "ssm:DescribeInstanceInformation",
+    ]
+
+    resources = ["*"]
+  }
 }
 
 resource "aws_iam_role" "check_kafka_ready" {
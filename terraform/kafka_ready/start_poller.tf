@@ -12,6 +12,7 @@ resource "aws_lambda_function" "start_poller" {
     variables = {
       STATE_MACHINE_ARN = "${aws_sfn_state_machine.poller.id}"
       TIMEOUT           = "${var.timeout}"
+      CHECK_BASELINE    = "${var.check_baseline}"
     }
   }
 }
@@ -28,6 +28,11 @@ variable "required_task_families" {
   default     = []
 }
 
+variable "check_baseline" {
+  description = "If true, the instance must pass its EC2 status checks and have its SSM agent online before Kafka is checked.  The check function must be able to reach the EC2 and SSM APIs from its subnets."
+  default     = "false"
+}
+
 variable "lambda_version" {
   type        = "string"
   description = "Lambda function version"
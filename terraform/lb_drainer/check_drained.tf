@@ -0,0 +1,53 @@
+resource "aws_lambda_function" "check_drained" {
+  function_name = "${format("%.64s", "lb-drain-chk-${var.autoscaling_group_name}")}"
+  description   = "Load balancer drainer - check_drained for ${var.autoscaling_group_name} group"
+  role          = "${aws_iam_role.check_drained.arn}"
+
+  s3_bucket = "${var.s3_bucket}"
+  s3_key    = "${var.lambda_version}/check-lb-drained.zip"
+  handler   = "check-lb-drained"
+  runtime   = "go1.x"
+}
+
+data "aws_iam_policy_document" "check_drained_assume_role" {
+  statement {
+    actions = ["sts:AssumeRole"]
+
+    principals {
+      type        = "Service"
+      identifiers = ["lambda.amazonaws.com"]
+    }
+  }
+}
+
+data "aws_iam_policy_document" "check_drained_policy" {
+  statement {
+    actions = [
+      "logs:CreateLogGroup",
+      "logs:CreateLogStream",
+      "logs:PutLogEvents",
+    ]
+
+    resources = ["*"]
+  }
+
+  statement {
+    actions = [
+      "elasticloadbalancing:DescribeTargetHealth",
+      "elasticloadbalancing:DescribeInstanceHealth",
+    ]
+
+    resources = ["*"]
+  }
+}
+
+resource "aws_iam_role" "check_drained" {
+  name               = "${format("%.64s", "lb-drain-chk-${var.autoscaling_group_name}")}"
+  assume_role_policy = "${data.aws_iam_policy_document.check_drained_assume_role.json}"
+}
+
+resource "aws_iam_role_policy" "check_drained" {
+  name   = "check-drained"
+  role   = "${aws_iam_role.check_drained.name}"
+  policy = "${data.aws_iam_policy_document.check_drained_policy.json}"
+}
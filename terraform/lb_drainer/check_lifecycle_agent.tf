@@ -0,0 +1,69 @@
+resource "aws_lambda_function" "check_lifecycle_agent" {
+  function_name = "${format("%.64s", "lb-drain-agent-${var.autoscaling_group_name}")}"
+  description   = "Load balancer drainer - check_lifecycle_agent for ${var.autoscaling_group_name} group"
+  role          = "${aws_iam_role.check_lifecycle_agent.arn}"
+
+  s3_bucket = "${var.s3_bucket}"
+  s3_key    = "${var.lambda_version}/check-lifecycle-agent.zip"
+  handler   = "check-lifecycle-agent"
+  runtime   = "go1.x"
+}
+
+data "aws_iam_policy_document" "check_lifecycle_agent_assume_role" {
+  statement {
+    actions = ["sts:AssumeRole"]
+
+    principals {
+      type        = "Service"
+      identifiers = ["lambda.amazonaws.com"]
+    }
+  }
+}
+
+data "aws_iam_policy_document" "check_lifecycle_agent_policy" {
+  statement {
+    actions = [
+      "logs:CreateLogGroup",
+      "logs:CreateLogStream",
+      "logs:PutLogEvents",
+    ]
+
+    resources = ["*"]
+  }
+
+  statement {
+    actions   = ["ec2:DescribeTags"]
+    resources = ["*"]
+  }
+}
+
+resource "aws_iam_role" "check_lifecycle_agent" {
+  name               = "${format("%.64s", "lb-drain-agent-${var.autoscaling_group_name}")}"
+  assume_role_policy = "${data.aws_iam_policy_document.check_lifecycle_agent_assume_role.json}"
+}
+
+resource "aws_iam_role_policy" "check_lifecycle_agent" {
+  name   = "check-lifecycle-agent"
+  role   = "${aws_iam_role.check_lifecycle_agent.name}"
+  policy = "${data.aws_iam_policy_document.check_lifecycle_agent_policy.json}"
+}
+
+# Attach to the instance role so that lifecycle-agent can report its progress
+data "aws_iam_policy_document" "lifecycle_agent" {
+  statement {
+    actions   = ["ec2:CreateTags"]
+    resources = ["arn:aws:ec2:*:*:instance/*"]
+
+    condition {
+      test     = "ForAllValues:StringLike"
+      variable = "aws:TagKeys"
+      values   = ["lifecycle-agent:*"]
+    }
+
+    condition {
+      test     = "StringEquals"
+      variable = "ec2:ResourceTag/aws:autoscaling:groupName"
+      values   = ["${var.autoscaling_group_name}"]
+    }
+  }
+}
@@ -0,0 +1,11 @@
+output "start_drainer_lambda_arn" {
+  value = "${aws_lambda_function.start_drainer.arn}"
+}
+
+output "step_function_arn" {
+  value = "${aws_sfn_state_machine.drainer.id}"
+}
+
+output "lifecycle_agent_policy_json" {
+  value = "${data.aws_iam_policy_document.lifecycle_agent.json}"
+}
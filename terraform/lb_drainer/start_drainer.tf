@@ -0,0 +1,76 @@
+resource "aws_lambda_function" "start_drainer" {
+  function_name = "${format("%.64s", "start-lb-drain-${var.autoscaling_group_name}")}"
+  description   = "Start load balancer drainer for ${var.autoscaling_group_name} group"
+  role          = "${aws_iam_role.start_drainer.arn}"
+
+  s3_bucket = "${var.s3_bucket}"
+  s3_key    = "${var.lambda_version}/start-lb-drainer.zip"
+  handler   = "start-lb-drainer"
+  runtime   = "go1.x"
+
+  environment {
+    variables = {
+      STATE_MACHINE_ARN        = "${aws_sfn_state_machine.drainer.id}"
+      TIMEOUT                  = "${var.timeout}"
+      TARGET_GROUP_ARNS        = "${join(",", var.target_group_arns)}"
+      LOAD_BALANCER_NAMES      = "${join(",", var.load_balancer_names)}"
+      DISCOVER_LOAD_BALANCERS  = "${var.discover_load_balancers}"
+      WAIT_FOR_LIFECYCLE_AGENT = "${var.wait_for_lifecycle_agent}"
+    }
+  }
+}
+
+data "aws_iam_policy_document" "start_drainer_assume_role" {
+  statement {
+    actions = ["sts:AssumeRole"]
+
+    principals {
+      type        = "Service"
+      identifiers = ["lambda.amazonaws.com"]
+    }
+  }
+}
+
+data "aws_iam_policy_document" "start_drainer_policy" {
+  statement {
+    actions = [
+      "logs:CreateLogGroup",
+      "logs:CreateLogStream",
+      "logs:PutLogEvents",
+    ]
+
+    resources = ["*"]
+  }
+
+  statement {
+    actions = [
+      "autoscaling:DescribeAutoScalingGroups",
+      "elasticloadbalancing:DescribeTargetHealth",
+      "elasticloadbalancing:DeregisterTargets",
+      "elasticloadbalancing:DeregisterInstancesFromLoadBalancer",
+    ]
+
+    resources = ["*"]
+  }
+
+  statement {
+    actions   = ["states:StartExecution"]
+    resources = ["${aws_sfn_state_machine.drainer.id}"]
+  }
+
+  statement {
+    actions   = ["states:DescribeExecution"]
+    resources = ["${replace(aws_sfn_state_machine.drainer.id, ":stateMachine:", ":execution:")}:*"]
+  }
+}
+
+resource "aws_iam_role" "start_drainer" {
+  name               = "${format("%.64s", "start-lb-drain-${var.autoscaling_group_name}")}"
+  assume_role_policy = "${data.aws_iam_policy_document.start_drainer_assume_role.json}"
+}
+
+resource "aws_iam_role_policy" "start_drainer" {
+  name   = "start-drainer"
+  role   = "${aws_iam_role.start_drainer.name}"
+  policy = "${data.aws_iam_policy_document.start_drainer_policy.json}"
+}
@@ -0,0 +1,163 @@
+resource "aws_sfn_state_machine" "drainer" {
+  name     = "lb_drainer-${var.autoscaling_group_name}"
+  role_arn = "${aws_iam_role.drainer.arn}"
+
+  definition = <<EOF
+{
+    "Comment": "Load balancer drainer - ${var.autoscaling_group_name}",
+    "StartAt": "CountRunningExecutions",
+    "States": {
+        "CountRunningExecutions": {
+            "Type": "Task",
+            "Resource": "${aws_lambda_function.count_running_executions.arn}",
+            "Next": "HaltIfRunningExecutions"
+        },
+        "HaltIfRunningExecutions": {
+            "Type": "Choice",
+            "Choices": [
+                {
+                    "Variable": "$.RunningExecutionCount",
+                    "NumericGreaterThan": 1,
+                    "Next": "AlreadyRunning"
+                }
+            ],
+            "Default": "CheckDrained"
+        },
+        "AlreadyRunning": {
+            "Type": "Fail",
+            "Cause": "Another execution is already running"
+        },
+        "CheckDeadline": {
+            "Type": "Task",
+            "Resource": "${aws_lambda_function.check_deadline.arn}",
+            "Next": "HaltIfPastDeadline"
+        },
+        "HaltIfPastDeadline": {
+            "Type": "Choice",
+            "Choices": [
+                {
+                    "Variable": "$.PastDeadline",
+                    "BooleanEquals": true,
+                    "Next": "AbandonLifecycleAction"
+                }
+            ],
+            "Default": "CheckDrained"
+        },
+        "CheckDrained": {
+            "Type": "Task",
+            "Resource": "${aws_lambda_function.check_drained.arn}",
+            "Next": "CompleteIfDrained"
+        },
+        "CompleteIfDrained": {
+            "Type": "Choice",
+            "Choices": [
+                {
+                    "And": [
+                        {
+                            "Variable": "$.Drained",
+                            "BooleanEquals": true
+                        },
+                        {
+                            "Variable": "$.Partial",
+                            "BooleanEquals": false
+                        }
+                    ],
+                    "Next": "CheckLifecycleAgent"
+                }
+            ],
+            "Default": "Heartbeat"
+        },
+        "CheckLifecycleAgent": {
+            "Type": "Task",
+            "Resource": "${aws_lambda_function.check_lifecycle_agent.arn}",
+            "ResultPath": "$.Agent",
+            "Next": "CompleteIfAgentFinished"
+        },
+        "CompleteIfAgentFinished": {
+            "Type": "Choice",
+            "Choices": [
+                {
+                    "Variable": "$.Agent.Status",
+                    "StringEquals": "failed",
+                    "Next": "AbandonLifecycleAction"
+                },
+                {
+                    "Variable": "$.Agent.Finished",
+                    "BooleanEquals": true,
+                    "Next": "ContinueLifecycleAction"
+                }
+            ],
+            "Default": "Heartbeat"
+        },
+        "Heartbeat": {
+            "Type": "Task",
+            "Resource": "${aws_lambda_function.record_lifecycle_heartbeat.arn}",
+            "Next": "WaitAndCheckAgain"
+        },
+        "WaitAndCheckAgain": {
+            "Type": "Wait",
+            "Seconds": ${var.wait_interval},
+            "Next": "CheckDeadline"
+        },
+        "ContinueLifecycleAction": {
+            "Type": "Pass",
+            "Result": {
+                "LifecycleActionResult": "CONTINUE"
+            },
+            "ResultPath": "$.Params",
+            "Next": "CompleteLifecycleAction"
+        },
+        "AbandonLifecycleAction": {
+            "Type": "Pass",
+            "Result": {
+                "LifecycleActionResult": "ABANDON"
+            },
+            "ResultPath": "$.Params",
+            "Next": "CompleteLifecycleAction"
+        },
+        "CompleteLifecycleAction": {
+            "Type": "Task",
+            "Resource": "${aws_lambda_function.complete_lifecycle_action.arn}",
+            "End": true
+        }
+    }
+}
+EOF
+}
+
+data "aws_iam_policy_document" "drainer_assume_role" {
+  statement {
+    actions = ["sts:AssumeRole"]
+
+    principals {
+      type        = "Service"
+      identifiers = ["states.amazonaws.com"]
+    }
+  }
+}
+
+data "aws_iam_policy_document" "drainer_policy" {
+  statement {
+    actions = ["lambda:InvokeFunction"]
+
+    resources = [
+      "${aws_lambda_function.count_running_executions.arn}",
+      "${aws_lambda_function.check_deadline.arn}",
+      "${aws_lambda_function.check_drained.arn}",
+      "${aws_lambda_function.check_lifecycle_agent.arn}",
+      "${aws_lambda_function.complete_lifecycle_action.arn}",
+      "${aws_lambda_function.record_lifecycle_heartbeat.arn}",
+    ]
+  }
+}
+
+resource "aws_iam_role" "drainer" {
+  name               = "${format("%.64s", "lb-drain-${var.autoscaling_group_name}")}"
+  assume_role_policy = "${data.aws_iam_policy_document.drainer_assume_role.json}"
+}
+
+resource "aws_iam_role_policy" "drainer" {
+  name   = "lb-drainer"
+  role   = "${aws_iam_role.drainer.name}"
+  policy = "${data.aws_iam_policy_document.drainer_policy.json}"
+}
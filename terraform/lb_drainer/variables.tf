@@ -0,0 +1,44 @@
+variable "autoscaling_group_name" {
+  description = "Name of Auto Scaling Group to be managed"
+  type        = "string"
+}
+
+variable "target_group_arns" {
+  description = "Target groups from which to deregister the instance, in addition to any discovered"
+  default     = []
+}
+
+variable "load_balancer_names" {
+  description = "Classic load balancers from which to deregister the instance, in addition to any discovered"
+  default     = []
+}
+
+variable "discover_load_balancers" {
+  description = "If true, also deregister the instance from the target groups and classic load balancers attached to the Auto Scaling Group"
+  default     = "true"
+}
+
+variable "wait_interval" {
+  description = "Number of seconds to wait between checks of deregistration progress"
+  default     = "30"
+}
+
+variable "timeout" {
+  description = "Timeout after which instance will be terminated even if not drained, as a Go duration string"
+  default     = "10m"
+}
+
+variable "wait_for_lifecycle_agent" {
+  description = "If true, the drain isn't complete until lifecycle-agent on the instance has run its Terminated hooks; if they fail, the lifecycle action is abandoned"
+  default     = "false"
+}
+
+variable "lambda_version" {
+  type        = "string"
+  description = "Lambda function version"
+}
+
+variable "s3_bucket" {
+  description = "S3 bucket in which Lambda functions live"
+  default     = "ec2-instance-lifecycle"
+}
@@ -0,0 +1,9 @@
+# Nothing records lifecycle heartbeats while the instance is awaited, so the
+# hook's own timeout must outlast the wait.
+resource "aws_autoscaling_lifecycle_hook" "ready" {
+  name                   = "ready_callback"
+  autoscaling_group_name = "${var.autoscaling_group_name}"
+  default_result         = "ABANDON"
+  heartbeat_timeout      = "${var.timeout_seconds + 60}"
+  lifecycle_transition   = "autoscaling:EC2_INSTANCE_LAUNCHING"
+}
@@ -0,0 +1,49 @@
+resource "aws_lambda_function" "delete_task_token" {
+  function_name = "${format("%.64s", "rdy-cb-del-${var.autoscaling_group_name}")}"
+  description   = "Readiness callback - delete_task_token for ${var.autoscaling_group_name} group"
+  role          = "${aws_iam_role.delete_task_token.arn}"
+
+  s3_bucket = "${var.s3_bucket}"
+  s3_key    = "${var.lambda_version}/delete-task-token.zip"
+  handler   = "delete-task-token"
+  runtime   = "go1.x"
+}
+
+data "aws_iam_policy_document" "delete_task_token_assume_role" {
+  statement {
+    actions = ["sts:AssumeRole"]
+
+    principals {
+      type        = "Service"
+      identifiers = ["lambda.amazonaws.com"]
+    }
+  }
+}
+
+data "aws_iam_policy_document" "delete_task_token_policy" {
+  statement {
+    actions = [
+      "logs:CreateLogGroup",
+      "logs:CreateLogStream",
+      "logs:PutLogEvents",
+    ]
+
+    resources = ["*"]
+  }
+
+  statement {
+    actions   = ["ssm:DeleteParameter"]
+    resources = ["arn:aws:ssm:*:*:parameter${var.token_parameter_prefix}/*"]
+  }
+}
+
+resource "aws_iam_role" "delete_task_token" {
+  name               = "${format("%.64s", "rdy-cb-del-${var.autoscaling_group_name}")}"
+  assume_role_policy = "${data.aws_iam_policy_document.delete_task_token_assume_role.json}"
+}
+
+resource "aws_iam_role_policy" "delete_task_token" {
+  name   = "delete-task-token"
+  role   = "${aws_iam_role.delete_task_token.name}"
+  policy = "${data.aws_iam_policy_document.delete_task_token_policy.json}"
+}
@@ -0,0 +1,12 @@
+output "start_callback_lambda_arn" {
+  value = "${aws_lambda_function.start_callback.arn}"
+}
+
+output "step_function_arn" {
+  value = "${aws_sfn_state_machine.callback.id}"
+}
+
+# Attach to the instance role so that signal-ready can run on the instances
+output "instance_policy_json" {
+  value = "${data.aws_iam_policy_document.signal_ready.json}"
+}
@@ -0,0 +1,49 @@
+resource "aws_lambda_function" "publish_task_token" {
+  function_name = "${format("%.64s", "rdy-cb-pub-${var.autoscaling_group_name}")}"
+  description   = "Readiness callback - publish_task_token for ${var.autoscaling_group_name} group"
+  role          = "${aws_iam_role.publish_task_token.arn}"
+
+  s3_bucket = "${var.s3_bucket}"
+  s3_key    = "${var.lambda_version}/publish-task-token.zip"
+  handler   = "publish-task-token"
+  runtime   = "go1.x"
+}
+
+data "aws_iam_policy_document" "publish_task_token_assume_role" {
+  statement {
+    actions = ["sts:AssumeRole"]
+
+    principals {
+      type        = "Service"
+      identifiers = ["lambda.amazonaws.com"]
+    }
+  }
+}
+
+data "aws_iam_policy_document" "publish_task_token_policy" {
+  statement {
+    actions = [
+      "logs:CreateLogGroup",
+      "logs:CreateLogStream",
+      "logs:PutLogEvents",
+    ]
+
+    resources = ["*"]
+  }
+
+  statement {
+    actions   = ["ssm:PutParameter"]
+    resources = ["arn:aws:ssm:*:*:parameter${var.token_parameter_prefix}/*"]
+  }
+}
+
+resource "aws_iam_role" "publish_task_token" {
+  name               = "${format("%.64s", "rdy-cb-pub-${var.autoscaling_group_name}")}"
+  assume_role_policy = "${data.aws_iam_policy_document.publish_task_token_assume_role.json}"
+}
+
+resource "aws_iam_role_policy" "publish_task_token" {
+  name   = "publish-task-token"
+  role   = "${aws_iam_role.publish_task_token.name}"
+  policy = "${data.aws_iam_policy_document.publish_task_token_policy.json}"
+}
@@ -0,0 +1,57 @@
+resource "aws_lambda_function" "start_callback" {
+  function_name = "${format("%.64s", "start-rdy-cb-${var.autoscaling_group_name}")}"
+  description   = "Start readiness callback for ${var.autoscaling_group_name} group"
+  role          = "${aws_iam_role.start_callback.arn}"
+
+  s3_bucket = "${var.s3_bucket}"
+  s3_key    = "${var.lambda_version}/start-ready-callback.zip"
+  handler   = "start-ready-callback"
+  runtime   = "go1.x"
+
+  environment {
+    variables = {
+      STATE_MACHINE_ARN      = "${aws_sfn_state_machine.callback.id}"
+      TIMEOUT                = "${var.timeout_seconds}s"
+      TOKEN_PARAMETER_PREFIX = "${var.token_parameter_prefix}"
+    }
+  }
+}
+
+data "aws_iam_policy_document" "start_callback_assume_role" {
+  statement {
+    actions = ["sts:AssumeRole"]
+
+    principals {
+      type        = "Service"
+      identifiers = ["lambda.amazonaws.com"]
+    }
+  }
+}
+
+data "aws_iam_policy_document" "start_callback_policy" {
+  statement {
+    actions = [
+      "logs:CreateLogGroup",
+      "logs:CreateLogStream",
+      "logs:PutLogEvents",
+    ]
+
+    resources = ["*"]
+  }
+
+  statement {
+    actions   = ["states:StartExecution"]
+    resources = ["${aws_sfn_state_machine.callback.id}"]
+  }
+}
+
+resource "aws_iam_role" "start_callback" {
+  name               = "${format("%.64s", "start-rdy-cb-${var.autoscaling_group_name}")}"
+  assume_role_policy = "${data.aws_iam_policy_document.start_callback_assume_role.json}"
+}
+
+resource "aws_iam_role_policy" "start_callback" {
+  name   = "start-callback"
+  role   = "${aws_iam_role.start_callback.name}"
+  policy = "${data.aws_iam_policy_document.start_callback_policy.json}"
+}
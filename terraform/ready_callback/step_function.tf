@@ -0,0 +1,148 @@
+resource "aws_sfn_state_machine" "callback" {
+  name     = "ready_callback-${var.autoscaling_group_name}"
+  role_arn = "${aws_iam_role.callback.arn}"
+
+  definition = <<EOF
+{
+    "Comment": "Readiness callback - ${var.autoscaling_group_name}",
+    "StartAt": "CountRunningExecutions",
+    "States": {
+        "CountRunningExecutions": {
+            "Type": "Task",
+            "Resource": "${aws_lambda_function.count_running_executions.arn}",
+            "Next": "HaltIfRunningExecutions"
+        },
+        "HaltIfRunningExecutions": {
+            "Type": "Choice",
+            "Choices": [
+                {
+                    "Variable": "$.RunningExecutionCount",
+                    "NumericGreaterThan": 1,
+                    "Next": "AlreadyRunning"
+                }
+            ],
+            "Default": "WaitForSignal"
+        },
+        "AlreadyRunning": {
+            "Type": "Fail",
+            "Cause": "Another execution is already running"
+        },
+        "WaitForSignal": {
+            "Type": "Task",
+            "Resource": "arn:aws:states:::lambda:invoke.waitForTaskToken",
+            "Parameters": {
+                "FunctionName": "${aws_lambda_function.publish_task_token.arn}",
+                "Payload": {
+                    "TaskToken.$": "$$.Task.Token",
+                    "Input.$": "$"
+                }
+            },
+            "HeartbeatSecondsPath": "$.TimeoutSeconds",
+            "ResultPath": "$.Callback",
+            "Catch": [
+                {
+                    "ErrorEquals": ["States.ALL"],
+                    "ResultPath": "$.CallbackError",
+                    "Next": "AbandonLifecycleAction"
+                }
+            ],
+            "Next": "ContinueLifecycleAction"
+        },
+        "ContinueLifecycleAction": {
+            "Type": "Pass",
+            "Result": {
+                "LifecycleActionResult": "CONTINUE"
+            },
+            "ResultPath": "$.Params",
+            "Next": "DeleteTaskToken"
+        },
+        "AbandonLifecycleAction": {
+            "Type": "Pass",
+            "Result": {
+                "LifecycleActionResult": "ABANDON"
+            },
+            "ResultPath": "$.Params",
+            "Next": "DeleteTaskToken"
+        },
+        "DeleteTaskToken": {
+            "Type": "Task",
+            "Resource": "${aws_lambda_function.delete_task_token.arn}",
+            "ResultPath": null,
+            "Retry": [
+                {
+                    "ErrorEquals": ["States.ALL"],
+                    "IntervalSeconds": 5,
+                    "MaxAttempts": 3
+                }
+            ],
+            "Catch": [
+                {
+                    "ErrorEquals": ["States.ALL"],
+                    "ResultPath": "$.DeleteTokenError",
+                    "Next": "CompleteLifecycleAction"
+                }
+            ],
+            "Next": "CompleteLifecycleAction"
+        },
+        "CompleteLifecycleAction": {
+            "Type": "Task",
+            "Resource": "${aws_lambda_function.complete_lifecycle_action.arn}",
+            "End": true
+        }
+    }
+}
+EOF
+}
+
+data "aws_iam_policy_document" "callback_assume_role" {
+  statement {
+    actions = ["sts:AssumeRole"]
+
+    principals {
+      type        = "Service"
+      identifiers = ["states.amazonaws.com"]
+    }
+  }
+}
+
+data "aws_iam_policy_document" "callback_policy" {
+  statement {
+    actions = ["lambda:InvokeFunction"]
+
+    resources = [
+      "${aws_lambda_function.count_running_executions.arn}",
+      "${aws_lambda_function.publish_task_token.arn}",
+      "${aws_lambda_function.delete_task_token.arn}",
+      "${aws_lambda_function.complete_lifecycle_action.arn}",
+    ]
+  }
+}
+
+resource "aws_iam_role" "callback" {
+  name               = "${format("%.64s", "rdy-cb-${var.autoscaling_group_name}")}"
+  assume_role_policy = "${data.aws_iam_policy_document.callback_assume_role.json}"
+}
+
+resource "aws_iam_role_policy" "callback" {
+  name   = "ready-callback"
+  role   = "${aws_iam_role.callback.name}"
+  policy = "${data.aws_iam_policy_document.callback_policy.json}"
+}
+
+# Task tokens aren't resources IAM can scope, so any instance with this policy
+# can signal any execution whose token it can read.
+data "aws_iam_policy_document" "signal_ready" {
+  statement {
+    actions   = ["ssm:GetParameter"]
+    resources = ["arn:aws:ssm:*:*:parameter${var.token_parameter_prefix}/*"]
+  }
+
+  statement {
+    actions = [
+      "states:SendTaskSuccess",
+      "states:SendTaskFailure",
+    ]
+
+    resources = ["*"]
+  }
+}
@@ -0,0 +1,24 @@
+variable "autoscaling_group_name" {
+  description = "Name of Auto Scaling Group to be managed"
+  type        = "string"
+}
+
+variable "timeout_seconds" {
+  description = "Number of seconds to wait for the instance to signal its readiness before it's terminated.  At most 7140, as the lifecycle hook's timeout is a minute longer and can be at most two hours."
+  default     = "300"
+}
+
+variable "token_parameter_prefix" {
+  description = "SSM parameter path under which each instance's task token is published, as /<prefix>/<instance ID>.  Must match the -prefix given to signal-ready."
+  default     = "/ec2-lifecycle/ready-token"
+}
+
+variable "lambda_version" {
+  type        = "string"
+  description = "Lambda function version"
+}
+
+variable "s3_bucket" {
+  description = "S3 bucket in which Lambda functions live"
+  default     = "ec2-instance-lifecycle"
+}
@@ -0,0 +1,108 @@
+resource "aws_lambda_function" "check_ready" {
+  function_name = "${format("%.64s", "rdy-check-${var.autoscaling_group_name}")}"
+  description   = "Readiness checker for ${var.autoscaling_group_name} Auto Scaling Group"
+  role          = "${aws_iam_role.check_ready.arn}"
+
+  s3_bucket = "${var.s3_bucket}"
+  s3_key    = "${var.lambda_version}/check-ready.zip"
+  handler   = "check-ready"
+  runtime   = "go1.x"
+
+  vpc_config {
+    subnet_ids         = ["${var.subnet_ids}"]
+    security_group_ids = ["${concat(list(aws_security_group.check_ready.id), var.security_group_ids)}"]
+  }
+}
+
+data "aws_iam_policy_document" "check_ready_assume_role" {
+  statement {
+    actions = ["sts:AssumeRole"]
+
+    principals {
+      type        = "Service"
+      identifiers = ["lambda.amazonaws.com"]
+    }
+  }
+}
+
+data "aws_iam_policy_document" "check_ready_policy" {
+  statement {
+    actions = [
+      "logs:CreateLogGroup",
+      "logs:CreateLogStream",
+      "logs:PutLogEvents",
+    ]
+
+    resources = ["*"]
+  }
+
+  # ecs-instance check
+  statement {
+    actions = [
+      "ecs:ListClusters",
+      "ecs:ListContainerInstances",
+      "ecs:DescribeContainerInstances",
+      "ecs:ListTasks",
+      "ecs:DescribeTasks",
+      "ecs:UpdateContainerInstancesState",
+      "ecs:PutAttributes",
+      "ecs:DeleteAttributes",
+    ]
+
+    resources = ["*"]
+  }
+
+  # lb-target-health check
+  statement {
+    actions = [
+      "elasticloadbalancing:DescribeTargetHealth",
+      "elasticloadbalancing:DescribeInstanceHealth",
+      "autoscaling:DescribeAutoScalingGroups",
+    ]
+
+    resources = ["*"]
+  }
+
+  # ec2-baseline check
+  statement {
+    actions = [
+      "ec2:DescribeInstanceStatus",
+      "ssm:DescribeInstanceInformation",
+    ]
+
+    resources = ["*"]
+  }
+
+  # ssm-command check
+  statement {
+    actions = [
+      "ssm:SendCommand",
+      "ssm:GetCommandInvocation",
+    ]
+
+    resources = ["*"]
+  }
+}
+
+resource "aws_iam_role" "check_ready" {
+  name               = "${format("%.64s", "rdy-check-${var.autoscaling_group_name}")}"
+  assume_role_policy = "${data.aws_iam_policy_document.check_ready_assume_role.json}"
+}
+
+resource "aws_iam_role_policy" "check_ready" {
+  name   = "check_ready"
+  role   = "${aws_iam_role.check_ready.name}"
+  policy = "${data.aws_iam_policy_document.check_ready_policy.json}"
+}
+
+resource "aws_security_group" "check_ready" {
+  name        = "${format("%.64s", "rdy-check-${var.autoscaling_group_name}")}"
+  description = "Allow egress traffic for readiness check Lambda function"
+
+  egress {
+    from_port   = 0
+    to_port     = 0
+    protocol    = "-1"
+    cidr_blocks = ["0.0.0.0/0"]
+  }
+}